@@ -0,0 +1,100 @@
+package realtime
+
+import (
+	"errors"
+	"runtime"
+	"time"
+
+	"github.com/anjmao/realtime/internal/wallclock"
+)
+
+// NewAlignedTicker returns ticker which ticks when wall clock time is a
+// multiple of period plus offset, e.g. period of 10s and zero offset ticks at
+// :00, :10, :20 and so on. Ticker is re-aligned after every tick and when
+// wall clock is set, so it follows wall clock changes and does not drift
+// after suspend. Ticks missed by more than a period are skipped.
+func NewAlignedTicker(period, offset time.Duration) *Ticker {
+	return newAlignedTicker(period, offset, wallAlignment)
+}
+
+// NewBootAlignedTicker is like NewAlignedTicker, but ticks are aligned to
+// multiples of time since boot.
+func NewBootAlignedTicker(period, offset time.Duration) *Ticker {
+	return newAlignedTicker(period, offset, bootAlignment)
+}
+
+type alignment int
+
+const (
+	wallAlignment alignment = iota
+	bootAlignment
+)
+
+func newAlignedTicker(period, offset time.Duration, clock alignment) *Ticker {
+	if period <= 0 {
+		panic(errors.New("non-positive interval for NewAlignedTicker"))
+	}
+
+	r := newTimerState(tickerEvent, newTimerChan(), nil, nil)
+	t := &Ticker{
+		C: r.c,
+		r: r,
+	}
+	now := alignedNow(clock)
+	first := untilAligned(now, period, offset)
+	if clock == wallAlignment {
+		// Boot aligned ticks stay on kernel timer grid, wall clock can be
+		// stepped or slewed so wall aligned ticker is re-armed to the next
+		// boundary on every tick and when wall clock is set.
+		boundary := now + int64(first)
+		r.next = func() time.Duration {
+			now := alignedNow(clock)
+			// Tick which fired slightly early or late is followed by the
+			// boundary after its own. When the boundary is already gone or
+			// more than a period away, ticks were missed or clock was set.
+			after := now + int64(untilAligned(now, period, offset))
+			boundary += int64(period)
+			if boundary < after || boundary > after+int64(period) {
+				boundary = after
+			}
+			return time.Duration(boundary - now)
+		}
+		r.unwatch = onWallClockSet(func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if r.state != timerActive || r.next == nil {
+				return
+			}
+			now := alignedNow(clock)
+			boundary = now + int64(untilAligned(now, period, offset))
+			r.arm(time.Duration(boundary-now), period)
+		})
+	}
+	r.start(first, period)
+	track(r)
+	runtime.SetFinalizer(t, (*Ticker).release)
+	return t
+}
+
+// wallNow and onWallClockSet are replaced in tests.
+var (
+	wallNow        = time.Now
+	onWallClockSet = wallclock.OnSet
+)
+
+func alignedNow(clock alignment) int64 {
+	if clock == bootAlignment {
+		return int64(nanotime())
+	}
+	return wallNow().UnixNano()
+}
+
+// untilAligned returns duration from now until next time which is a multiple
+// of period plus offset.
+func untilAligned(now int64, period, offset time.Duration) time.Duration {
+	rem := (now - int64(offset)) % int64(period)
+	if rem < 0 {
+		rem += int64(period)
+	}
+	return period - time.Duration(rem)
+}
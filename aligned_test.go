@@ -0,0 +1,165 @@
+package realtime
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUntilAligned(t *testing.T) {
+	tests := []struct {
+		now      int64
+		period   time.Duration
+		offset   time.Duration
+		expected time.Duration
+	}{
+		{now: 0, period: 10, offset: 0, expected: 10},
+		{now: 3, period: 10, offset: 0, expected: 7},
+		{now: 3, period: 10, offset: 5, expected: 2},
+		{now: 7, period: 10, offset: 5, expected: 8},
+		{now: 3, period: 10, offset: -5, expected: 2},
+		{now: 12, period: 10, offset: 25, expected: 3},
+	}
+	for _, test := range tests {
+		if actual := untilAligned(test.now, test.period, test.offset); actual != test.expected {
+			t.Errorf("untilAligned(%d, %d, %d) = %d, want %d", test.now, test.period, test.offset, actual, test.expected)
+		}
+	}
+}
+
+func testAlignedTicker(t *testing.T, ticker *Ticker, now func() int64, period, offset time.Duration) {
+	defer ticker.Stop()
+
+	const slop = 10 * time.Millisecond
+	for i := 0; i < 5; i++ {
+		<-ticker.C
+		phase := time.Duration(now()-int64(offset)) % period
+		if phase < 0 {
+			phase += period
+		}
+		if phase > slop {
+			t.Fatalf("tick %d is %s after boundary", i, phase)
+		}
+	}
+}
+
+func TestAlignedTicker(t *testing.T) {
+	const period, offset = 50 * time.Millisecond, 20 * time.Millisecond
+	testAlignedTicker(t, NewAlignedTicker(period, offset), func() int64 {
+		return time.Now().UnixNano()
+	}, period, offset)
+}
+
+func TestBootAlignedTicker(t *testing.T) {
+	const period, offset = 50 * time.Millisecond, 20 * time.Millisecond
+	testAlignedTicker(t, NewBootAlignedTicker(period, offset), func() int64 {
+		return int64(nanotime())
+	}, period, offset)
+}
+
+// useFakeWall replaces wall clock with one which follows fake poller clock
+// from zero, step moves it. Wall clock set callback is captured in set.
+func useFakeWall(p *fakePoller) (step func(time.Duration), set func(), restore func()) {
+	start := p.nanotime()
+	var (
+		mu       sync.Mutex
+		offset   time.Duration
+		callback func()
+	)
+	prevNow, prevOnSet := wallNow, onWallClockSet
+	wallNow = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return time.Unix(0, int64(p.nanotime()-start)+int64(offset))
+	}
+	onWallClockSet = func(f func()) func() {
+		mu.Lock()
+		defer mu.Unlock()
+		callback = f
+		return func() {}
+	}
+	step = func(d time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		offset += d
+	}
+	set = func() {
+		mu.Lock()
+		f := callback
+		mu.Unlock()
+		f()
+	}
+	return step, set, func() {
+		wallNow, onWallClockSet = prevNow, prevOnSet
+	}
+}
+
+func lastArmed(p *fakePoller) time.Duration {
+	armed := p.armedDurations()
+	return armed[len(armed)-1]
+}
+
+func TestAlignedTickerLateTick(t *testing.T) {
+	p, restore := useFakePoller()
+	defer restore()
+	_, _, restoreWall := useFakeWall(p)
+	defer restoreWall()
+
+	ticker := NewAlignedTicker(10*time.Second, 0)
+	defer ticker.Stop()
+	if d := lastArmed(p); d != 10*time.Second {
+		t.Fatalf("first tick armed after %s, want 10s", d)
+	}
+
+	// Tick fires 6s late, boundary at 20s must not be skipped.
+	p.suspend(16 * time.Second)
+	<-ticker.C
+	if d := lastArmed(p); d != 4*time.Second {
+		t.Fatalf("tick armed after %s, want 4s", d)
+	}
+
+	// Ticks missed by more than a period are skipped.
+	p.suspend(25 * time.Second)
+	<-ticker.C
+	if d := lastArmed(p); d != 9*time.Second {
+		t.Fatalf("tick armed after %s, want 9s", d)
+	}
+}
+
+func TestAlignedTickerClockSet(t *testing.T) {
+	p, restore := useFakePoller()
+	defer restore()
+	step, set, restoreWall := useFakeWall(p)
+	defer restoreWall()
+
+	ticker := NewAlignedTicker(10*time.Second, 0)
+	defer ticker.Stop()
+	p.armedDurations()
+
+	// First arm is re-aligned when wall clock is set.
+	step(3 * time.Second)
+	set()
+	if d := lastArmed(p); d != 7*time.Second {
+		t.Fatalf("tick armed after %s, want 7s", d)
+	}
+	p.advance(7 * time.Second)
+	<-ticker.C
+	if d := lastArmed(p); d != 10*time.Second {
+		t.Fatalf("tick armed after %s, want 10s", d)
+	}
+
+	// Step back noticed only on tick does not delay ticks by more than a
+	// period.
+	step(-25 * time.Second)
+	p.advance(10 * time.Second)
+	<-ticker.C
+	if d := lastArmed(p); d != 5*time.Second {
+		t.Fatalf("tick armed after %s, want 5s", d)
+	}
+
+	ticker.Stop()
+	set()
+	if armed := p.armedDurations(); len(armed) != 0 {
+		t.Fatalf("stopped ticker was re-armed %v", armed)
+	}
+}
@@ -0,0 +1,104 @@
+// Package backoff retries operations with delays measured by realtime clock,
+// so time spent in suspend counts towards delays and elapsed time budget.
+package backoff
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Strategy computes delays between attempts.
+type Strategy interface {
+	// Delay returns delay before retry attempt, attempts are counted from 1,
+	// prev is the previous delay or zero before the first retry.
+	Delay(attempt int, prev time.Duration) time.Duration
+}
+
+// Constant waits the same delay before every retry.
+type Constant time.Duration
+
+// Delay implements Strategy.
+func (c Constant) Delay(int, time.Duration) time.Duration {
+	return time.Duration(c)
+}
+
+// Exponential multiplies delay by Multiplier after every attempt.
+type Exponential struct {
+	// Initial delay, 100ms if zero.
+	Initial time.Duration
+	// Max caps delay, zero means no cap.
+	Max time.Duration
+	// Multiplier of delay, 2 if zero.
+	Multiplier float64
+	// Jitter randomly moves delay by up to this fraction in either direction.
+	Jitter float64
+}
+
+// Delay implements Strategy.
+func (e Exponential) Delay(attempt int, _ time.Duration) time.Duration {
+	initial := e.Initial
+	if initial <= 0 {
+		initial = 100 * time.Millisecond
+	}
+	multiplier := e.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+	d := float64(initial) * math.Pow(multiplier, float64(attempt-1))
+	// Delay of high attempt overflows to +Inf, it is capped before jitter is
+	// applied as jitter of infinite delay is NaN.
+	d = float64(capDelay(d, e.Max))
+	if e.Jitter > 0 {
+		d += d * e.Jitter * (2*random() - 1)
+	}
+	return capDelay(d, e.Max)
+}
+
+// DecorrelatedJitter picks random delay between Base and three times the
+// previous delay, as described in "Exponential Backoff And Jitter" by AWS.
+type DecorrelatedJitter struct {
+	// Base is minimum delay, 100ms if zero.
+	Base time.Duration
+	// Max caps delay, zero means no cap.
+	Max time.Duration
+}
+
+// Delay implements Strategy.
+func (j DecorrelatedJitter) Delay(_ int, prev time.Duration) time.Duration {
+	base := j.Base
+	if base <= 0 {
+		base = 100 * time.Millisecond
+	}
+	if prev < base {
+		prev = base
+	}
+	d := float64(base) + random()*float64(3*prev-base)
+	return capDelay(d, j.Max)
+}
+
+func capDelay(d float64, max time.Duration) time.Duration {
+	if max > 0 && d > float64(max) {
+		return max
+	}
+	if d >= math.MaxInt64 {
+		return math.MaxInt64
+	}
+	if !(d > 0) {
+		// Negative or NaN.
+		return 0
+	}
+	return time.Duration(d)
+}
+
+var (
+	rndMu sync.Mutex
+	rnd   = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+func random() float64 {
+	rndMu.Lock()
+	defer rndMu.Unlock()
+	return rnd.Float64()
+}
@@ -0,0 +1,216 @@
+package backoff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/anjmao/realtime/internal/clocktest"
+)
+
+var errTemporary = errors.New("temporary")
+
+func TestExponential(t *testing.T) {
+	e := Exponential{Initial: time.Second, Max: 5 * time.Second}
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, want := range expected {
+		if d := e.Delay(i+1, 0); d != want {
+			t.Fatalf("attempt %d: expected %s, got %s", i+1, want, d)
+		}
+	}
+
+	e = Exponential{Initial: time.Second, Multiplier: 3, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		if d := e.Delay(2, 0); d < 1500*time.Millisecond || d > 4500*time.Millisecond {
+			t.Fatalf("jittered delay %s out of range", d)
+		}
+	}
+}
+
+func TestExponentialHighAttempt(t *testing.T) {
+	capped := Exponential{Initial: time.Second, Max: time.Minute, Jitter: 0.5}
+	uncapped := Exponential{Initial: time.Second, Jitter: 0.5}
+	for _, attempt := range []int{64, 1100, 1 << 20, math.MaxInt32} {
+		for i := 0; i < 100; i++ {
+			if d := capped.Delay(attempt, 0); d < 30*time.Second || d > time.Minute {
+				t.Fatalf("attempt %d: capped delay %s out of range", attempt, d)
+			}
+			if d := uncapped.Delay(attempt, 0); d < math.MaxInt64/2 {
+				t.Fatalf("attempt %d: uncapped delay %s out of range", attempt, d)
+			}
+		}
+	}
+}
+
+func TestDecorrelatedJitter(t *testing.T) {
+	j := DecorrelatedJitter{Base: time.Second, Max: 10 * time.Second}
+	var prev time.Duration
+	for i := 1; i <= 100; i++ {
+		d := j.Delay(i, prev)
+		max := 3 * prev
+		if max < 3*time.Second {
+			max = 3 * time.Second
+		}
+		if max > 10*time.Second {
+			max = 10 * time.Second
+		}
+		if d < time.Second || d > max {
+			t.Fatalf("delay %s after %s out of range", d, prev)
+		}
+		prev = d
+	}
+}
+
+func TestRetry(t *testing.T) {
+	var calls int
+	var notified []time.Duration
+	err := Retry(context.Background(), func(context.Context) error {
+		calls++
+		if calls < 3 {
+			return errTemporary
+		}
+		return nil
+	}, WithStrategy(Constant(time.Millisecond)), WithNotify(func(err error, d time.Duration) {
+		notified = append(notified, d)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 || len(notified) != 2 {
+		t.Fatalf("expected 3 calls and 2 notifications, got %d and %d", calls, len(notified))
+	}
+}
+
+func TestRetryMaxAttempts(t *testing.T) {
+	var calls int
+	err := Retry(context.Background(), func(context.Context) error {
+		calls++
+		return errTemporary
+	}, WithStrategy(Constant(0)), WithMaxAttempts(4))
+	if err != errTemporary || calls != 4 {
+		t.Fatalf("expected 4 calls and temporary error, got %d calls and %v", calls, err)
+	}
+}
+
+func TestRetryPermanent(t *testing.T) {
+	errFatal := errors.New("fatal")
+	var calls int
+	err := Retry(context.Background(), func(context.Context) error {
+		calls++
+		return fmt.Errorf("wrapped: %w", Permanent(errFatal))
+	}, WithStrategy(Constant(0)))
+	if err != errFatal || calls != 1 {
+		t.Fatalf("expected single call and fatal error, got %d calls and %v", calls, err)
+	}
+	if Permanent(nil) != nil {
+		t.Fatal("expected Permanent(nil) to be nil")
+	}
+}
+
+func TestRetryContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	err := Retry(ctx, func(context.Context) error {
+		cancel()
+		return errTemporary
+	})
+	if err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+// retryAsync runs Retry in goroutine and advances clock by delay whenever
+// Retry waits.
+func retryAsync(clock *clocktest.Clock, delay time.Duration, op Operation, opts ...Option) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- Retry(context.Background(), op, opts...)
+	}()
+	for {
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(time.Millisecond):
+			if clock.Timers() > 0 {
+				clock.Advance(delay)
+			}
+		}
+	}
+}
+
+func TestRetryMaxElapsedTime(t *testing.T) {
+	clock := clocktest.New()
+	var calls int32
+	err := retryAsync(clock, time.Minute, func(context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		return errTemporary
+	}, WithClock(clock), WithStrategy(Constant(time.Minute)), WithMaxElapsedTime(5*time.Minute))
+	if err != errTemporary || calls != 6 {
+		t.Fatalf("expected 6 calls and temporary error, got %d calls and %v", calls, err)
+	}
+}
+
+func TestRetryMaxElapsedTimeSuspend(t *testing.T) {
+	clock := clocktest.New()
+	var calls int32
+	op := func(context.Context) error {
+		if atomic.AddInt32(&calls, 1) == 2 {
+			// Machine is suspended during attempt.
+			clock.Advance(time.Hour)
+		}
+		return errTemporary
+	}
+	err := retryAsync(clock, time.Minute, op, WithClock(clock), WithStrategy(Constant(time.Minute)), WithMaxElapsedTime(5*time.Minute))
+	if err != errTemporary || calls != 2 {
+		t.Fatalf("expected suspend to use up budget after 2 calls, got %d calls and %v", calls, err)
+	}
+
+	// Awake time excludes the suspend.
+	atomic.StoreInt32(&calls, 0)
+	var awake int64
+	awakeTime := func(c *config) {
+		c.awake = func() func() time.Duration {
+			return func() time.Duration {
+				return time.Duration(atomic.LoadInt64(&awake))
+			}
+		}
+	}
+	op = func(context.Context) error {
+		if atomic.AddInt32(&calls, 1) == 2 {
+			clock.Advance(time.Hour)
+		}
+		// Awake time of the delay before next attempt.
+		atomic.AddInt64(&awake, int64(time.Minute))
+		return errTemporary
+	}
+	err = retryAsync(clock, time.Minute, op, WithClock(clock), WithStrategy(Constant(time.Minute)), WithMaxElapsedTime(5*time.Minute), awakeTime)
+	if err != errTemporary || calls != 5 {
+		t.Fatalf("expected 5 calls with awake time budget, got %d calls and %v", calls, err)
+	}
+}
+
+func TestRetryAttemptTimeout(t *testing.T) {
+	clock := clocktest.New()
+	var calls int32
+	err := retryAsync(clock, time.Second, func(ctx context.Context) error {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	}, WithClock(clock), WithStrategy(Constant(time.Second)), WithAttemptTimeout(time.Second))
+	if err != nil || calls != 2 {
+		t.Fatalf("expected timed out attempt to be retried, got %d calls and %v", calls, err)
+	}
+
+	err = Retry(context.Background(), func(ctx context.Context) error {
+		<-ctx.Done()
+		return Permanent(ctx.Err())
+	}, WithAttemptTimeout(10*time.Millisecond))
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
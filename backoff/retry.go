@@ -0,0 +1,173 @@
+package backoff
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/anjmao/realtime"
+)
+
+// Operation is retried by Retry until it succeeds.
+type Operation func(ctx context.Context) error
+
+// PermanentError stops retries.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent wraps err, so Retry does not retry operation and returns err.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
+
+// Option configures Retry.
+type Option func(*config)
+
+type config struct {
+	strategy       Strategy
+	maxAttempts    int
+	maxElapsed     time.Duration
+	attemptTimeout time.Duration
+	clock          realtime.Clock
+	notify         func(err error, delay time.Duration)
+	// awake returns time since first call excluding suspend, nil means
+	// elapsed time is measured by clock.
+	awake func() func() time.Duration
+}
+
+// WithStrategy sets strategy of delays, default is Exponential with 100ms
+// initial and 10s max delay.
+func WithStrategy(s Strategy) Option {
+	return func(c *config) {
+		c.strategy = s
+	}
+}
+
+// WithMaxAttempts limits number of attempts including the first one.
+func WithMaxAttempts(n int) Option {
+	return func(c *config) {
+		c.maxAttempts = n
+	}
+}
+
+// WithMaxElapsedTime stops retries when next attempt would start after d
+// since the first one. Time spent in suspend counts, unless WithAwakeTime is
+// used.
+func WithMaxElapsedTime(d time.Duration) Option {
+	return func(c *config) {
+		c.maxElapsed = d
+	}
+}
+
+// WithAttemptTimeout cancels context of every attempt after d. Context of
+// timed out attempt returns context.DeadlineExceeded.
+func WithAttemptTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.attemptTimeout = d
+	}
+}
+
+// WithAwakeTime makes WithMaxElapsedTime count only time when machine was
+// awake, so a suspend does not use up the budget. Awake time is realtime
+// BOOTTIME elapsed minus time suspended, which equals elapsed MONOTONIC time.
+func WithAwakeTime() Option {
+	return func(c *config) {
+		c.awake = monotonicSince
+	}
+}
+
+// WithClock sets clock used for delays and elapsed time.
+func WithClock(clock realtime.Clock) Option {
+	return func(c *config) {
+		c.clock = clock
+	}
+}
+
+// WithNotify calls f after every failed attempt which is going to be retried.
+func WithNotify(f func(err error, delay time.Duration)) Option {
+	return func(c *config) {
+		c.notify = f
+	}
+}
+
+func monotonicSince() func() time.Duration {
+	start := time.Now()
+	return func() time.Duration {
+		return time.Since(start)
+	}
+}
+
+// Retry calls op until it succeeds, returns permanent error, attempts or
+// elapsed time budget are exhausted or ctx is done. It returns the last error
+// of op, unwrapped from PermanentError, or ctx error.
+func Retry(ctx context.Context, op Operation, opts ...Option) error {
+	c := config{
+		strategy: Exponential{Initial: 100 * time.Millisecond, Max: 10 * time.Second},
+		clock:    realtime.SystemClock(),
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	start := c.clock.Now()
+	elapsed := func() time.Duration {
+		return c.clock.Now().Sub(start)
+	}
+	if c.awake != nil {
+		elapsed = c.awake()
+	}
+
+	var delay time.Duration
+	for attempt := 1; ; attempt++ {
+		err := c.attempt(ctx, op)
+		if err == nil {
+			return nil
+		}
+		var p *PermanentError
+		if errors.As(err, &p) {
+			return p.Err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if c.maxAttempts > 0 && attempt >= c.maxAttempts {
+			return err
+		}
+		delay = c.strategy.Delay(attempt, delay)
+		if c.maxElapsed > 0 && elapsed()+delay > c.maxElapsed {
+			return err
+		}
+		if c.notify != nil {
+			c.notify(err, delay)
+		}
+
+		t := c.clock.NewTimer(delay)
+		select {
+		case <-t.Chan():
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+func (c *config) attempt(ctx context.Context, op Operation) error {
+	if c.attemptTimeout <= 0 {
+		return op(ctx)
+	}
+	tctx, cancel := realtime.WithClockTimeout(ctx, c.clock, c.attemptTimeout)
+	defer cancel()
+	return op(tctx)
+}
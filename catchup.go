@@ -0,0 +1,59 @@
+package realtime
+
+import "errors"
+
+// CatchUpPolicy defines how ticker delivers ticks it missed, e.g. while
+// machine was suspended.
+type CatchUpPolicy struct {
+	mode  catchUpMode
+	burst int
+}
+
+type catchUpMode int
+
+const (
+	skipMissed catchUpMode = iota
+	fireOnceWithCount
+	fireAll
+	realign
+)
+
+var (
+	// SkipMissed sends single tick and discards missed ticks. It is the
+	// default policy.
+	SkipMissed = CatchUpPolicy{mode: skipMissed}
+
+	// FireOnceWithCount sends single tick and adds missed ticks to the count
+	// returned by Ticker.Missed.
+	FireOnceWithCount = CatchUpPolicy{mode: fireOnceWithCount}
+
+	// Realign sends single tick and restarts period from that moment, so
+	// following ticks are period apart from wake up instead of staying on
+	// original schedule.
+	Realign = CatchUpPolicy{mode: realign}
+)
+
+// FireAll sends every missed tick as fast as receiver reads them, but keeps
+// no more than maxBurst ticks pending. Ticks over maxBurst are added to the
+// count returned by Ticker.Missed.
+func FireAll(maxBurst int) CatchUpPolicy {
+	if maxBurst < 1 {
+		panic(errors.New("non-positive burst for FireAll"))
+	}
+	return CatchUpPolicy{mode: fireAll, burst: maxBurst}
+}
+
+// WithCatchUp sets ticker catch-up policy. Timers ignore it.
+func WithCatchUp(p CatchUpPolicy) TimerOption {
+	return func(r *timer) {
+		r.catchUp = p
+	}
+}
+
+// maxPending returns number of ticks which can wait for receiver.
+func (p CatchUpPolicy) maxPending() uint64 {
+	if p.mode == fireAll {
+		return uint64(p.burst)
+	}
+	return 1
+}
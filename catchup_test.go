@@ -0,0 +1,126 @@
+package realtime
+
+import (
+	"testing"
+	"time"
+)
+
+const suspendPeriod = time.Minute
+
+// suspendTicker starts ticker with policy, suspends machine for 8 hours and
+// 30 seconds and returns ticker and time of wake up.
+func suspendTicker(p *fakePoller, policy CatchUpPolicy) (*Ticker, Time) {
+	ticker := NewTicker(suspendPeriod, WithCatchUp(policy))
+	p.suspend(8*time.Hour + 30*time.Second)
+	return ticker, Now()
+}
+
+func expectNoTick(t *testing.T, ticker *Ticker) {
+	t.Helper()
+	select {
+	case <-ticker.C:
+		t.Fatal("unexpected tick")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestCatchUpSkipMissed(t *testing.T) {
+	p, restore := useFakePoller()
+	defer restore()
+
+	start := Now()
+	ticker, wake := suspendTicker(p, SkipMissed)
+	defer ticker.Stop()
+	<-ticker.C
+	expectNoTick(t, ticker)
+	if n := ticker.Missed(); n != 0 {
+		t.Fatalf("expected no missed ticks, got %d", n)
+	}
+
+	// Ticker stays on original schedule.
+	p.advanceToNext()
+	<-ticker.C
+	if d := Now().Sub(wake); d != 30*time.Second {
+		t.Fatalf("expected next tick 30s after wake up, got %s", d)
+	}
+	if d := Now().Sub(start) % suspendPeriod; d != 0 {
+		t.Fatalf("tick is %s off schedule", d)
+	}
+}
+
+func TestCatchUpFireOnceWithCount(t *testing.T) {
+	p, restore := useFakePoller()
+	defer restore()
+
+	ticker, _ := suspendTicker(p, FireOnceWithCount)
+	defer ticker.Stop()
+	<-ticker.C
+	expectNoTick(t, ticker)
+	if n := ticker.Missed(); n != 479 {
+		t.Fatalf("expected 479 missed ticks, got %d", n)
+	}
+	if n := ticker.Missed(); n != 0 {
+		t.Fatalf("expected missed count to be reset, got %d", n)
+	}
+}
+
+func TestCatchUpFireAll(t *testing.T) {
+	for _, modern := range []bool{false, true} {
+		func() {
+			if modern {
+				defer withModernTimers()()
+			}
+			p, restore := useFakePoller()
+			defer restore()
+
+			ticker, _ := suspendTicker(p, FireAll(10))
+			defer ticker.Stop()
+			for i := 0; i < 10; i++ {
+				<-ticker.C
+			}
+			expectNoTick(t, ticker)
+			if n := ticker.Missed(); n != 470 {
+				t.Fatalf("modern=%v: expected 470 missed ticks, got %d", modern, n)
+			}
+
+			// Ticks pending at Stop are discarded.
+			p.suspend(5 * suspendPeriod)
+			ticker.Stop()
+			select {
+			case <-ticker.C:
+				if modern {
+					t.Fatal("unexpected tick after Stop")
+				}
+			default:
+			}
+			expectNoTick(t, ticker)
+		}()
+	}
+}
+
+func TestCatchUpRealign(t *testing.T) {
+	p, restore := useFakePoller()
+	defer restore()
+
+	ticker, wake := suspendTicker(p, Realign)
+	defer ticker.Stop()
+	<-ticker.C
+	expectNoTick(t, ticker)
+
+	for i := 1; i <= 2; i++ {
+		p.advanceToNext()
+		<-ticker.C
+		if d := Now().Sub(wake); d != time.Duration(i)*suspendPeriod {
+			t.Fatalf("expected tick %d period after wake up, got %s", i, d)
+		}
+	}
+}
+
+func TestFireAllBurst(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic for zero burst")
+		}
+	}()
+	FireAll(0)
+}
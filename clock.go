@@ -0,0 +1,41 @@
+package realtime
+
+import "time"
+
+// Clock is a source of time and timers. Packages built on top of realtime
+// accept Clock so they can be tested with fake time.
+type Clock interface {
+	Now() Time
+	NewTimer(d time.Duration) ClockTimer
+}
+
+// ClockTimer is a timer created by Clock.
+type ClockTimer interface {
+	// Chan returns channel which receives time when timer expires.
+	Chan() <-chan Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// SystemClock returns Clock backed by realtime timers.
+func SystemClock() Clock {
+	return systemClock{}
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() Time {
+	return Now()
+}
+
+func (systemClock) NewTimer(d time.Duration) ClockTimer {
+	return clockTimer{NewTimer(d)}
+}
+
+type clockTimer struct {
+	*Timer
+}
+
+func (t clockTimer) Chan() <-chan Time {
+	return t.C
+}
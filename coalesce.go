@@ -0,0 +1,191 @@
+package realtime
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WithSlack allows timer to expire up to slack after its deadline. Timers
+// whose windows overlap are batched into a single engine event, so they wake
+// up the process once. Tickers ignore it.
+func WithSlack(slack time.Duration) TimerOption {
+	return func(r *timer) {
+		if slack > 0 {
+			r.slack = slack
+		}
+	}
+}
+
+// coalescer batches one shot timers with slack into shared engine events.
+type coalescer struct {
+	mu      sync.Mutex
+	groups  map[uint64]*timerGroup // by engine event id
+	members map[uint64]*timerGroup // by timer id
+}
+
+// timerGroup is an engine event which fires at the start of window shared by
+// all its members.
+type timerGroup struct {
+	id      uint64
+	start   int64
+	end     int64
+	members map[uint64]*groupMember
+}
+
+type groupMember struct {
+	start   int64
+	end     int64
+	handler timerHandler
+}
+
+var coalesced = &coalescer{
+	groups:  map[uint64]*timerGroup{},
+	members: map[uint64]*timerGroup{},
+}
+
+// add arms timer id to expire in window [d, d+slack] from now joining group
+// with overlapping window if there is one.
+func (c *coalescer) add(id uint64, d, slack time.Duration, handler timerHandler) {
+	if d <= 0 {
+		d = 1
+	}
+	now := int64(clockNow())
+	m := &groupMember{
+		start:   now + int64(d),
+		end:     now + int64(d+slack),
+		handler: handler,
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removeLocked(id)
+
+	for _, g := range c.groups {
+		if g.start > m.end || m.start > g.end {
+			continue
+		}
+		g.members[id] = m
+		c.members[id] = g
+		if m.end < g.end {
+			g.end = m.end
+		}
+		if m.start > g.start {
+			// Group fires later to satisfy new member.
+			g.start = m.start
+			armEvent(g.id, timerEvent, time.Duration(g.start-now), 0, c.fire)
+		}
+		return
+	}
+
+	g := &timerGroup{
+		id:      nextEventID(),
+		start:   m.start,
+		end:     m.end,
+		members: map[uint64]*groupMember{id: m},
+	}
+	c.groups[g.id] = g
+	c.members[id] = g
+	armEvent(g.id, timerEvent, d, 0, c.fire)
+}
+
+// remove disarms timer id and reports whether it was armed.
+func (c *coalescer) remove(id uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.removeLocked(id)
+}
+
+func (c *coalescer) removeLocked(id uint64) bool {
+	g, ok := c.members[id]
+	if !ok {
+		return false
+	}
+	delete(c.members, id)
+	delete(g.members, id)
+	if len(g.members) == 0 {
+		delete(c.groups, g.id)
+		stopEvent(g.id)
+	}
+	return true
+}
+
+// remaining returns time left until timer id expires.
+func (c *coalescer) remaining(id uint64) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	g, ok := c.members[id]
+	if !ok {
+		return 0
+	}
+	return time.Duration(g.start - int64(clockNow()))
+}
+
+func (c *coalescer) fire(gid, _ uint64) {
+	now := int64(clockNow())
+	c.mu.Lock()
+	g, ok := c.groups[gid]
+	if !ok {
+		c.mu.Unlock()
+		return
+	}
+	due := map[uint64]timerHandler{}
+	for id, m := range g.members {
+		// Member could join after group expired, it waits for next fire.
+		if m.start <= now {
+			due[id] = m.handler
+			delete(g.members, id)
+			delete(c.members, id)
+		}
+	}
+	if len(g.members) == 0 {
+		delete(c.groups, gid)
+	} else {
+		g.start, g.end = 0, 1<<63-1
+		for _, m := range g.members {
+			if m.start > g.start {
+				g.start = m.start
+			}
+			if m.end < g.end {
+				g.end = m.end
+			}
+		}
+		armEvent(gid, timerEvent, time.Duration(g.start-now), 0, c.fire)
+	}
+	c.mu.Unlock()
+
+	if len(due) > 1 {
+		atomic.AddUint64(&stats.savedWakeups, uint64(len(due)-1))
+	}
+	for id, handler := range due {
+		callMember(id, handler)
+	}
+}
+
+func callMember(id uint64, handler timerHandler) {
+	defer recoverHandler(id, timerEvent)
+	handler(id, 1)
+}
+
+// expand replaces group events in infos with their members.
+func (c *coalescer) expand(infos []TimerInfo) []TimerInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.groups) == 0 {
+		return infos
+	}
+	expanded := infos[:0:0]
+	for _, info := range infos {
+		g, ok := c.groups[info.ID]
+		if !ok {
+			expanded = append(expanded, info)
+			continue
+		}
+		for id := range g.members {
+			member := info
+			member.ID = id
+			expanded = append(expanded, member)
+		}
+	}
+	return expanded
+}
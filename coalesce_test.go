@@ -0,0 +1,115 @@
+package realtime
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCoalesceTimers(t *testing.T) {
+	p, restore := useFakePoller()
+	defer restore()
+	saved := Stats().SavedWakeups
+
+	var fired int32
+	f := func() { atomic.AddInt32(&fired, 1) }
+	start := Now()
+	AfterFunc(10*time.Millisecond, f, WithSlack(10*time.Millisecond))
+	AfterFunc(12*time.Millisecond, f, WithSlack(10*time.Millisecond))
+	timer := NewTimer(15*time.Millisecond, WithSlack(time.Millisecond))
+	// Does not overlap with others.
+	late := NewTimer(50*time.Millisecond, WithSlack(10*time.Millisecond))
+
+	if n := len(p.timers()); n != 2 {
+		t.Fatalf("expected 2 engine events, got %d", n)
+	}
+	if n := len(Timers()); n != 4 {
+		t.Fatalf("expected 4 live timers, got %d", n)
+	}
+	if d := timer.Remaining(); d != 15*time.Millisecond {
+		t.Fatalf("expected 15ms remaining, got %s", d)
+	}
+
+	p.advanceToNext()
+	tick := <-timer.C
+	if d := tick.Sub(start); d != 15*time.Millisecond {
+		t.Fatalf("expected timers to fire at latest start 15ms, got %s", d)
+	}
+	waitFor(t, func() bool { return atomic.LoadInt32(&fired) == 2 })
+	if d := Stats().SavedWakeups - saved; d != 2 {
+		t.Fatalf("expected 2 saved wakeups, got %d", d)
+	}
+
+	p.advanceToNext()
+	if d := (<-late.C).Sub(start); d != 50*time.Millisecond {
+		t.Fatalf("expected late timer to fire at 50ms, got %s", d)
+	}
+}
+
+func TestCoalesceStop(t *testing.T) {
+	p, restore := useFakePoller()
+	defer restore()
+
+	t1 := NewTimer(10*time.Millisecond, WithSlack(10*time.Millisecond))
+	t2 := NewTimer(12*time.Millisecond, WithSlack(10*time.Millisecond))
+	if !t2.Stop() {
+		t.Fatal("Stop of coalesced timer = false, want true")
+	}
+	t1.Reset(20 * time.Millisecond)
+	if n := len(p.timers()); n != 1 {
+		t.Fatalf("expected 1 engine event, got %d", n)
+	}
+	t1.Stop()
+	if n := len(p.timers()); n != 0 {
+		t.Fatalf("expected no engine events, got %d", n)
+	}
+}
+
+func TestCoalesceLateJoin(t *testing.T) {
+	p, restore := useFakePoller()
+	defer restore()
+
+	start := Now()
+	t1 := NewTimer(10*time.Millisecond, WithSlack(10*time.Millisecond))
+	gid := coalesced.members[t1.r.id].id
+
+	// Group expires, but second timer joins before group handler runs.
+	p.mu.Lock()
+	p.now += int64(10 * time.Millisecond)
+	p.mu.Unlock()
+	t2 := NewTimer(time.Millisecond, WithSlack(10*time.Millisecond))
+	coalesced.fire(gid, 1)
+	<-t1.C
+	select {
+	case <-t2.C:
+		t.Fatal("timer fired before its deadline")
+	default:
+	}
+
+	p.advanceToNext()
+	if d := (<-t2.C).Sub(start); d != 11*time.Millisecond {
+		t.Fatalf("expected second timer to fire at 11ms, got %s", d)
+	}
+}
+
+func TestCoalesceRealEngine(t *testing.T) {
+	start := Now()
+	t1 := NewTimer(20*time.Millisecond, WithSlack(20*time.Millisecond))
+	t2 := NewTimer(30*time.Millisecond, WithSlack(20*time.Millisecond))
+	<-t1.C
+	<-t2.C
+	if d := Since(start); d < 30*time.Millisecond {
+		t.Fatalf("timers fired after %s, before latest deadline", d)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,76 @@
+package realtime
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// WithTimeout is like context.WithTimeout, but timeout is measured by
+// realtime timer. Runtime timers used by context.WithTimeout stop during
+// suspend, so its deadline passes late after wake up.
+//
+// Deadline reports wall clock time at which timeout passes if machine is not
+// suspended, or deadline of parent if it is earlier.
+func WithTimeout(parent context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	return WithClockTimeout(parent, SystemClock(), d)
+}
+
+// WithClockTimeout is like WithTimeout, but measures timeout with clock.
+func WithClockTimeout(parent context.Context, clock Clock, d time.Duration) (context.Context, context.CancelFunc) {
+	c := &timeoutCtx{
+		Context:  parent,
+		deadline: time.Now().Add(d),
+		done:     make(chan struct{}),
+	}
+	t := clock.NewTimer(d)
+	go func() {
+		select {
+		case <-t.Chan():
+			c.cancel(context.DeadlineExceeded)
+		case <-parent.Done():
+			c.cancel(parent.Err())
+		case <-c.done:
+		}
+		t.Stop()
+	}()
+	return c, func() { c.cancel(context.Canceled) }
+}
+
+// timeoutCtx has its own done channel and error, so contexts derived from it
+// see DeadlineExceeded when timeout passes.
+type timeoutCtx struct {
+	context.Context
+	deadline time.Time
+	done     chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+func (c *timeoutCtx) Deadline() (time.Time, bool) {
+	if parent, ok := c.Context.Deadline(); ok && parent.Before(c.deadline) {
+		return parent, true
+	}
+	return c.deadline, true
+}
+
+func (c *timeoutCtx) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *timeoutCtx) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *timeoutCtx) cancel(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	c.err = err
+	close(c.done)
+}
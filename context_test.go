@@ -0,0 +1,93 @@
+package realtime
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWithTimeout(t *testing.T) {
+	p, restore := useFakePoller()
+	defer restore()
+
+	start := time.Now()
+	ctx, cancel := WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	if !ok || deadline.Before(start.Add(time.Second)) || deadline.After(time.Now().Add(time.Second)) {
+		t.Fatalf("unexpected deadline %v, %v", deadline, ok)
+	}
+	waitFor(t, func() bool { return len(p.timers()) == 1 })
+
+	// Timeout passes during suspend.
+	p.suspend(time.Second)
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("context was not cancelled after timeout")
+	}
+	if err := ctx.Err(); err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestWithTimeoutChild(t *testing.T) {
+	p, restore := useFakePoller()
+	defer restore()
+
+	ctx, cancel := WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	child, cancelChild := context.WithCancel(ctx)
+	defer cancelChild()
+	waitFor(t, func() bool { return len(p.timers()) == 1 })
+
+	p.advance(time.Second)
+	select {
+	case <-child.Done():
+	case <-time.After(time.Second):
+		t.Fatal("child context was not cancelled after timeout")
+	}
+	if err := child.Err(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestWithTimeoutParentCancel(t *testing.T) {
+	p, restore := useFakePoller()
+	defer restore()
+
+	parent, cancelParent := context.WithCancel(context.Background())
+	ctx, cancel := WithTimeout(parent, time.Hour)
+	defer cancel()
+	cancelParent()
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("context was not cancelled with parent")
+	}
+	if err := ctx.Err(); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	waitFor(t, func() bool { return len(p.timers()) == 0 })
+}
+
+func TestWithTimeoutCancel(t *testing.T) {
+	p, restore := useFakePoller()
+	defer restore()
+
+	parent, cancelParent := context.WithTimeout(context.Background(), time.Minute)
+	defer cancelParent()
+	ctx, cancel := WithTimeout(parent, time.Hour)
+	parentDeadline, _ := parent.Deadline()
+	if deadline, _ := ctx.Deadline(); !deadline.Equal(parentDeadline) {
+		t.Fatalf("expected earlier parent deadline %v, got %v", parentDeadline, deadline)
+	}
+
+	cancel()
+	if err := ctx.Err(); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	// Timer is stopped once context is cancelled.
+	waitFor(t, func() bool { return len(p.timers()) == 0 })
+}
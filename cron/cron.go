@@ -0,0 +1,411 @@
+// Package cron runs jobs on cron schedules. Schedules follow wall clock in a
+// time zone. On Linux scheduler sleeps on CLOCK_REALTIME timer armed to the
+// next run, so it wakes up on time after suspend and right when wall clock is
+// set. Elsewhere wall clock is polled every second.
+package cron
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/anjmao/realtime"
+	"github.com/anjmao/realtime/internal/wallclock"
+)
+
+// maxSleep bounds scheduler sleep with WithClock or WithWallClock, wall clock
+// is re-read at least this often.
+const maxSleep = time.Minute
+
+// OverlapPolicy decides what happens when job is due while its previous run
+// has not finished.
+type OverlapPolicy int
+
+const (
+	// SkipIfRunning drops the run.
+	SkipIfRunning OverlapPolicy = iota
+	// Queue runs job again after the previous run finishes.
+	Queue
+	// Concurrent starts the run in parallel.
+	Concurrent
+)
+
+// MissedPolicy decides what happens with runs which were missed by more than
+// grace period, e.g. during suspend or when wall clock was stepped forward.
+type MissedPolicy int
+
+const (
+	// RunOnce runs job once for all missed runs.
+	RunOnce MissedPolicy = iota
+	// SkipMissed skips missed runs and waits for the next one.
+	SkipMissed
+)
+
+// Option configures Cron.
+type Option func(*Cron)
+
+// WithLocation sets time zone of schedules without CRON_TZ prefix. Default is
+// time.Local.
+func WithLocation(loc *time.Location) Option {
+	return func(c *Cron) {
+		c.loc = loc
+	}
+}
+
+// WithClock sets clock used for scheduler timers. Scheduler sleeps on its
+// timers for at most a minute, so wall clock changes are noticed within a
+// minute.
+func WithClock(clock realtime.Clock) Option {
+	return func(c *Cron) {
+		c.clock = clock
+		c.poll = true
+	}
+}
+
+// WithWallClock sets source of wall clock time. Default is time.Now. Wall
+// clock is polled the same way as with WithClock.
+func WithWallClock(now func() time.Time) Option {
+	return func(c *Cron) {
+		c.wall = now
+		c.poll = true
+	}
+}
+
+// WithWallClockSet sets channel on which source of WithWallClock reports that
+// wall clock was set, scheduler re-reads wall clock then without waiting for
+// the next poll.
+func WithWallClockSet(set <-chan struct{}) Option {
+	return func(c *Cron) {
+		c.wallSet = set
+	}
+}
+
+// WithGracePeriod sets how late a run may start before it is considered
+// missed. Default is one second.
+func WithGracePeriod(d time.Duration) Option {
+	return func(c *Cron) {
+		c.grace = d
+	}
+}
+
+// WithLogger sets logger which receives skipped runs, job panics and wall
+// clock steps.
+func WithLogger(l realtime.Logger) Option {
+	return func(c *Cron) {
+		c.logger = l
+	}
+}
+
+// JobOption configures a job.
+type JobOption func(*entry)
+
+// WithOverlap sets overlap policy of job. Default is SkipIfRunning.
+func WithOverlap(p OverlapPolicy) JobOption {
+	return func(e *entry) {
+		e.overlap = p
+	}
+}
+
+// WithMissed sets missed runs policy of job. Default is RunOnce.
+func WithMissed(p MissedPolicy) JobOption {
+	return func(e *entry) {
+		e.missed = p
+	}
+}
+
+// EntryID identifies job added to Cron.
+type EntryID int
+
+// Entry describes scheduled job.
+type Entry struct {
+	ID   EntryID
+	Spec string
+	// Next is time of the next run, zero if schedule has no more runs.
+	Next time.Time
+	// Prev is scheduled time of the previous run, zero if job has not run.
+	Prev time.Time
+}
+
+// Cron is a job scheduler, it is safe for concurrent use.
+type Cron struct {
+	mu      sync.Mutex
+	entries map[EntryID]*entry
+	lastID  EntryID
+	loc     *time.Location
+	clock   realtime.Clock
+	wall    func() time.Time
+	grace   time.Duration
+	logger  realtime.Logger
+	// poll is set when scheduler can not sleep on wall clock timer.
+	poll    bool
+	wallSet <-chan struct{}
+
+	wake    chan struct{}
+	done    chan struct{}
+	started bool
+	stopped bool
+	jobs    sync.WaitGroup
+}
+
+type entry struct {
+	id       EntryID
+	spec     string
+	schedule *Schedule
+	job      func()
+	overlap  OverlapPolicy
+	missed   MissedPolicy
+	next     time.Time
+	prev     time.Time
+	running  int
+	queued   int
+}
+
+// New returns scheduler which does not run jobs until Start is called.
+func New(opts ...Option) *Cron {
+	c := &Cron{
+		entries: map[EntryID]*entry{},
+		loc:     time.Local,
+		clock:   realtime.SystemClock(),
+		wall:    time.Now,
+		grace:   time.Second,
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Add schedules job to run on spec, see Parse for its syntax.
+func (c *Cron) Add(spec string, job func(), opts ...JobOption) (EntryID, error) {
+	schedule, err := Parse(spec)
+	if err != nil {
+		return 0, err
+	}
+	e := &entry{spec: spec, schedule: schedule, job: job}
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	c.mu.Lock()
+	c.lastID++
+	e.id = c.lastID
+	e.next = schedule.Next(c.wall().In(c.loc))
+	c.entries[e.id] = e
+	c.mu.Unlock()
+	c.notify()
+	return e.id, nil
+}
+
+// Remove unschedules job, its running run is not interrupted.
+func (c *Cron) Remove(id EntryID) {
+	c.mu.Lock()
+	if e, ok := c.entries[id]; ok {
+		e.queued = 0
+		delete(c.entries, id)
+	}
+	c.mu.Unlock()
+	c.notify()
+}
+
+// Entries returns scheduled jobs ordered by id.
+func (c *Cron) Entries() []Entry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entries := make([]Entry, 0, len(c.entries))
+	for _, e := range c.entries {
+		entries = append(entries, Entry{ID: e.id, Spec: e.spec, Next: e.next, Prev: e.prev})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ID < entries[j].ID
+	})
+	return entries
+}
+
+// Start starts scheduler goroutine.
+func (c *Cron) Start() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.started || c.stopped {
+		return
+	}
+	c.started = true
+	go c.run()
+}
+
+// Stop stops scheduler and waits for running jobs to finish. Queued runs are
+// dropped.
+func (c *Cron) Stop() {
+	c.mu.Lock()
+	if !c.stopped {
+		c.stopped = true
+		close(c.done)
+	}
+	c.mu.Unlock()
+	c.jobs.Wait()
+}
+
+func (c *Cron) notify() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (c *Cron) run() {
+	t := c.newTimer()
+	defer t.stop()
+	lastWall, lastBoot := c.wall(), c.clock.Now()
+	for {
+		now, boot := c.wall(), c.clock.Now()
+		if step := now.Sub(lastWall) - boot.Sub(lastBoot); step > c.grace || step < -c.grace {
+			c.log(realtime.LevelInfo, "wall clock changed", "step", step)
+		}
+		lastWall, lastBoot = now, boot
+
+		t.reset(now, c.dispatch(now.In(c.loc)))
+		select {
+		case <-t.wall:
+		case <-t.clockChan():
+		case <-c.wake:
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// timer wakes up scheduler at the next run. It is either wall clock timer or
+// realtime timer which polls wall clock, the latter also wakes up when
+// WithWallClockSet reports that wall clock was set.
+type timer struct {
+	wall  <-chan struct{}
+	wt    *wallclock.Timer
+	clock realtime.ClockTimer
+}
+
+func (c *Cron) newTimer() *timer {
+	if c.poll {
+		return &timer{wall: c.wallSet, clock: c.clock.NewTimer(maxSleep)}
+	}
+	wt := wallclock.NewTimer(never)
+	return &timer{wall: wt.C, wt: wt}
+}
+
+// never is time of the next run when there are no runs.
+var never = time.Date(2200, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func (t *timer) reset(now, next time.Time) {
+	if next.IsZero() {
+		next = never
+	}
+	if t.wt != nil {
+		t.wt.Reset(next)
+		return
+	}
+	sleep := next.Sub(now)
+	if sleep > maxSleep {
+		sleep = maxSleep
+	}
+	t.clock.Reset(sleep)
+}
+
+func (t *timer) clockChan() <-chan realtime.Time {
+	if t.clock == nil {
+		return nil
+	}
+	return t.clock.Chan()
+}
+
+func (t *timer) stop() {
+	if t.wt != nil {
+		t.wt.Stop()
+		return
+	}
+	t.clock.Stop()
+}
+
+// dispatch starts due jobs and returns time of the next run, zero if there
+// are no runs.
+func (c *Cron) dispatch(now time.Time) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var next time.Time
+	if c.stopped {
+		return next
+	}
+	for _, e := range c.entries {
+		if e.next.IsZero() {
+			continue
+		}
+		if !e.next.After(now) {
+			if late := now.Sub(e.next); late <= c.grace || e.missed == RunOnce {
+				c.start(e)
+			} else {
+				c.log(realtime.LevelInfo, "missed cron run skipped", "job", e.spec, "scheduled", e.next, "late", late)
+			}
+			// Runs which were missed too are folded into this one. Entries
+			// which are not due yet keep their next run when wall clock
+			// steps back, so runs are not repeated.
+			e.prev = e.next
+			e.next = e.schedule.Next(now)
+			if e.next.IsZero() {
+				continue
+			}
+		}
+		if next.IsZero() || e.next.Before(next) {
+			next = e.next
+		}
+	}
+	return next
+}
+
+// start must be called with mu held.
+func (c *Cron) start(e *entry) {
+	if e.running > 0 {
+		switch e.overlap {
+		case SkipIfRunning:
+			c.log(realtime.LevelDebug, "cron run skipped, previous run is still running", "job", e.spec)
+			return
+		case Queue:
+			e.queued++
+			return
+		}
+	}
+	e.running++
+	c.jobs.Add(1)
+	go c.runJob(e)
+}
+
+func (c *Cron) runJob(e *entry) {
+	defer c.jobs.Done()
+	for {
+		c.call(e)
+		c.mu.Lock()
+		if e.queued > 0 && !c.stopped {
+			e.queued--
+			c.mu.Unlock()
+			continue
+		}
+		e.queued = 0
+		e.running--
+		c.mu.Unlock()
+		return
+	}
+}
+
+func (c *Cron) call(e *entry) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.log(realtime.LevelError, "cron job panicked", "job", e.spec, "panic", fmt.Sprint(r))
+		}
+	}()
+	e.job()
+}
+
+func (c *Cron) log(level realtime.Level, msg string, keyvals ...interface{}) {
+	if c.logger != nil {
+		c.logger.Log(level, msg, keyvals...)
+	}
+}
@@ -0,0 +1,299 @@
+package cron
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anjmao/realtime"
+	"github.com/anjmao/realtime/internal/clocktest"
+)
+
+// fakeWall is wall clock which follows fake realtime clock and can be
+// stepped.
+type fakeWall struct {
+	clock *clocktest.Clock
+	start realtime.Time
+	base  time.Time
+	// setc notifies scheduler that wall clock was set.
+	setc chan struct{}
+
+	mu     sync.Mutex
+	offset time.Duration
+}
+
+func newFakeWall(t *testing.T, base string) *fakeWall {
+	b, err := time.Parse(time.RFC3339, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := clocktest.New()
+	return &fakeWall{clock: clock, start: clock.Now(), base: b, setc: make(chan struct{}, 1)}
+}
+
+func (w *fakeWall) now() time.Time {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.base.Add(w.clock.Now().Sub(w.start) + w.offset).UTC()
+}
+
+func (w *fakeWall) step(d time.Duration) {
+	w.mu.Lock()
+	w.offset += d
+	w.mu.Unlock()
+}
+
+func (w *fakeWall) cron(opts ...Option) *Cron {
+	opts = append([]Option{WithClock(w.clock), WithWallClock(w.now), WithWallClockSet(w.setc), WithLocation(time.UTC)}, opts...)
+	return New(opts...)
+}
+
+// set steps wall clock and notifies scheduler the way wall clock timer does.
+func (w *fakeWall) set(d time.Duration) {
+	w.step(d)
+	w.setc <- struct{}{}
+}
+
+// waitPrev waits until job has been dispatched for scheduled time.
+func waitPrev(t *testing.T, c *Cron, id EntryID, want string) {
+	w, err := time.Parse(time.RFC3339, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, e := range c.Entries() {
+			if e.ID == id && e.Prev.Equal(w) {
+				return
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("job %d was not dispatched for %s, entries %v", id, want, c.Entries())
+}
+
+type recordLogger struct {
+	mu   sync.Mutex
+	msgs []string
+}
+
+func (l *recordLogger) Log(level realtime.Level, msg string, keyvals ...interface{}) {
+	l.mu.Lock()
+	l.msgs = append(l.msgs, msg)
+	l.mu.Unlock()
+}
+
+func (l *recordLogger) has(msg string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, m := range l.msgs {
+		if m == msg {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCronRunsJob(t *testing.T) {
+	w := newFakeWall(t, "2026-03-01T09:59:59Z")
+	c := w.cron()
+	ran := make(chan struct{}, 10)
+	id, err := c.Add("0 10 * * *", func() { ran <- struct{}{} })
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Start()
+	defer c.Stop()
+
+	w.clock.WaitTimers(1)
+	w.clock.Advance(time.Second)
+	select {
+	case <-ran:
+	case <-time.After(5 * time.Second):
+		t.Fatal("job did not run")
+	}
+	waitPrev(t, c, id, "2026-03-01T10:00:00Z")
+	if next := c.Entries()[0].Next; !next.Equal(time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected next run %s", next)
+	}
+
+	c.Remove(id)
+	if n := len(c.Entries()); n != 0 {
+		t.Fatalf("expected no entries after Remove, got %d", n)
+	}
+}
+
+func TestCronMissedRuns(t *testing.T) {
+	w := newFakeWall(t, "2026-03-01T09:30:00Z")
+	c := w.cron()
+	var mu sync.Mutex
+	runs := map[string]int{}
+	job := func(name string) func() {
+		return func() {
+			mu.Lock()
+			runs[name]++
+			mu.Unlock()
+		}
+	}
+	once, _ := c.Add("0 * * * *", job("once"), WithMissed(RunOnce))
+	skip, _ := c.Add("0 * * * *", job("skip"), WithMissed(SkipMissed))
+	c.Start()
+
+	// Suspend over eight hourly runs.
+	w.clock.WaitTimers(1)
+	w.clock.Advance(8 * time.Hour)
+	// Prev is the first missed run, the rest are folded into it.
+	waitPrev(t, c, once, "2026-03-01T10:00:00Z")
+	waitPrev(t, c, skip, "2026-03-01T10:00:00Z")
+	c.Stop()
+
+	if runs["once"] != 1 {
+		t.Fatalf("expected RunOnce job to run once, got %d", runs["once"])
+	}
+	if runs["skip"] != 0 {
+		t.Fatalf("expected SkipMissed job to be skipped, got %d runs", runs["skip"])
+	}
+	for _, e := range c.Entries() {
+		if !e.Next.Equal(time.Date(2026, 3, 1, 18, 0, 0, 0, time.UTC)) {
+			t.Fatalf("unexpected next run %s", e.Next)
+		}
+	}
+}
+
+func TestCronWallClockStepBack(t *testing.T) {
+	w := newFakeWall(t, "2026-03-01T09:59:59Z")
+	logger := &recordLogger{}
+	c := w.cron(WithLogger(logger))
+	var mu sync.Mutex
+	runs := 0
+	id, _ := c.Add("0 10 * * *", func() {
+		mu.Lock()
+		runs++
+		mu.Unlock()
+	})
+	c.Start()
+
+	w.clock.WaitTimers(1)
+	w.clock.Advance(time.Second)
+	waitPrev(t, c, id, "2026-03-01T10:00:00Z")
+
+	// Wall clock goes back over the run, it must not be repeated.
+	w.step(-30 * time.Minute)
+	for i := 0; i < 40; i++ {
+		w.clock.Advance(time.Minute)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !logger.has("wall clock changed") && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	c.Stop()
+
+	if !logger.has("wall clock changed") {
+		t.Fatal("expected wall clock step to be logged")
+	}
+	if runs != 1 {
+		t.Fatalf("expected job to run once, got %d", runs)
+	}
+}
+
+func TestCronOverlap(t *testing.T) {
+	tests := []struct {
+		policy  OverlapPolicy
+		started int
+		runs    int
+	}{
+		{SkipIfRunning, 1, 1},
+		{Queue, 1, 3},
+		{Concurrent, 3, 3},
+	}
+	for _, tt := range tests {
+		w := newFakeWall(t, "2026-03-01T10:00:00Z")
+		c := w.cron()
+		release := make(chan struct{})
+		started := make(chan struct{}, 10)
+		var mu sync.Mutex
+		runs := 0
+		id, _ := c.Add("* * * * * *", func() {
+			started <- struct{}{}
+			<-release
+			mu.Lock()
+			runs++
+			mu.Unlock()
+		}, WithOverlap(tt.policy))
+		c.Start()
+
+		w.clock.WaitTimers(1)
+		for _, at := range []string{"2026-03-01T10:00:01Z", "2026-03-01T10:00:02Z", "2026-03-01T10:00:03Z"} {
+			w.clock.Advance(time.Second)
+			waitPrev(t, c, id, at)
+		}
+		for i := 0; i < tt.started; i++ {
+			select {
+			case <-started:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("policy %d: expected %d runs started, got %d", tt.policy, tt.started, i)
+			}
+		}
+		close(release)
+		// Stop drops queued runs, wait for them first.
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			mu.Lock()
+			n := runs
+			mu.Unlock()
+			if n >= tt.runs {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		c.Stop()
+
+		if runs != tt.runs {
+			t.Fatalf("policy %d: expected %d runs, got %d", tt.policy, tt.runs, runs)
+		}
+	}
+}
+
+func TestCronWallClockSet(t *testing.T) {
+	w := newFakeWall(t, "2026-03-01T09:00:00Z")
+	c := w.cron()
+	ran := make(chan struct{}, 10)
+	id, _ := c.Add("0 10 * * *", func() { ran <- struct{}{} })
+	c.Start()
+	defer c.Stop()
+
+	// Wall clock is set forward to the run, scheduler does not wait for its
+	// timer.
+	w.clock.WaitTimers(1)
+	w.set(time.Hour)
+	select {
+	case <-ran:
+	case <-time.After(5 * time.Second):
+		t.Fatal("job did not run after wall clock was set")
+	}
+	waitPrev(t, c, id, "2026-03-01T10:00:00Z")
+}
+
+func TestCronSystemClock(t *testing.T) {
+	c := New()
+	ran := make(chan time.Time, 10)
+	if _, err := c.Add("* * * * * *", func() { ran <- time.Now() }); err != nil {
+		t.Fatal(err)
+	}
+	c.Start()
+	defer c.Stop()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case at := <-ran:
+			// Wall clock timer fires at the second boundary, polling may be
+			// late by up to a second.
+			if ns := at.Nanosecond(); runtime.GOOS == "linux" && ns > int(500*time.Millisecond) {
+				t.Fatalf("run started %dns after second boundary", ns)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatal("job did not run")
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// bounds of a schedule field.
+type bounds struct {
+	min, max uint
+	names    map[string]uint
+}
+
+var (
+	seconds = bounds{0, 59, nil}
+	minutes = bounds{0, 59, nil}
+	hours   = bounds{0, 23, nil}
+	dom     = bounds{1, 31, nil}
+	months  = bounds{1, 12, map[string]uint{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dow = bounds{0, 7, map[string]uint{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// starBit marks field which was given as * or ?.
+const starBit = 1 << 63
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+// Parse parses standard 5 field expression (minute, hour, day of month,
+// month, day of week), 6 field expression with leading seconds field or a
+// descriptor like @hourly. Expression may be prefixed with CRON_TZ=Zone or
+// TZ=Zone to evaluate it in the time zone, otherwise it is evaluated in the
+// location of time passed to Next.
+func Parse(spec string) (*Schedule, error) {
+	s := &Schedule{}
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "CRON_TZ=") || strings.HasPrefix(spec, "TZ=") {
+		i := strings.IndexAny(spec, " \t")
+		if i < 0 {
+			return nil, fmt.Errorf("cron: missing expression after time zone in %q", spec)
+		}
+		name := spec[strings.Index(spec, "=")+1 : i]
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			return nil, fmt.Errorf("cron: bad time zone %q: %v", name, err)
+		}
+		s.loc = loc
+		spec = strings.TrimSpace(spec[i:])
+	}
+
+	if strings.HasPrefix(spec, "@") {
+		expanded, ok := descriptors[strings.ToLower(spec)]
+		if !ok {
+			return nil, fmt.Errorf("cron: unknown descriptor %q", spec)
+		}
+		spec = expanded
+	}
+
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("cron: expected 5 or 6 fields, got %d in %q", len(fields), spec)
+	}
+
+	var err error
+	for i, f := range []struct {
+		bits *uint64
+		b    bounds
+	}{
+		{&s.second, seconds},
+		{&s.minute, minutes},
+		{&s.hour, hours},
+		{&s.dom, dom},
+		{&s.month, months},
+		{&s.dow, dow},
+	} {
+		if *f.bits, err = parseField(fields[i], f.b); err != nil {
+			return nil, err
+		}
+	}
+	// Sunday can be 0 or 7.
+	if s.dow&(1<<7) != 0 {
+		s.dow = s.dow&^(1<<7) | 1
+	}
+	return s, nil
+}
+
+// parseField parses comma separated list of ranges into bit set.
+func parseField(field string, b bounds) (uint64, error) {
+	var bits uint64
+	for _, expr := range strings.Split(field, ",") {
+		r, err := parseRange(expr, b)
+		if err != nil {
+			return 0, err
+		}
+		bits |= r
+	}
+	return bits, nil
+}
+
+// parseRange parses number, name, a-b range, * or ? with optional /step.
+func parseRange(expr string, b bounds) (uint64, error) {
+	var (
+		start, end, step uint = 0, 0, 1
+		extra            uint64
+	)
+	rangeAndStep := strings.SplitN(expr, "/", 2)
+	lowAndHigh := strings.SplitN(rangeAndStep[0], "-", 2)
+
+	if lowAndHigh[0] == "*" || lowAndHigh[0] == "?" {
+		if len(lowAndHigh) > 1 {
+			return 0, fmt.Errorf("cron: bad range %q", expr)
+		}
+		start, end = b.min, b.max
+		if b.max == 7 {
+			// Day of week wildcard does not need duplicate Sunday.
+			end = 6
+		}
+		extra = starBit
+	} else {
+		var err error
+		if start, err = parseValue(lowAndHigh[0], b); err != nil {
+			return 0, err
+		}
+		end = start
+		if len(lowAndHigh) > 1 {
+			if end, err = parseValue(lowAndHigh[1], b); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	if len(rangeAndStep) > 1 {
+		n, err := strconv.ParseUint(rangeAndStep[1], 10, 8)
+		if err != nil || n == 0 {
+			return 0, fmt.Errorf("cron: bad step in %q", expr)
+		}
+		step = uint(n)
+		if len(lowAndHigh) == 1 && extra == 0 {
+			// N/step means N-max/step.
+			end = b.max
+		}
+		if step > 1 {
+			extra = 0
+		}
+	}
+
+	if start < b.min || end > b.max || start > end {
+		return 0, fmt.Errorf("cron: %q out of range %d-%d", expr, b.min, b.max)
+	}
+	var bits uint64
+	for i := start; i <= end; i += step {
+		bits |= 1 << i
+	}
+	return bits | extra, nil
+}
+
+func parseValue(s string, b bounds) (uint, error) {
+	if v, ok := b.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	n, err := strconv.ParseUint(s, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("cron: bad value %q", s)
+	}
+	return uint(n), nil
+}
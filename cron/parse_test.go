@@ -0,0 +1,94 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"* * * foo *",
+		"@fortnightly",
+		"CRON_TZ=Nowhere/Town 0 3 * * *",
+		"TZ=UTC",
+	} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) expected error", spec)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	tests := []struct {
+		spec string
+		from string
+		want []string
+	}{
+		{"0 3 * * *", "2026-03-01T10:00:00-05:00", []string{"2026-03-02T03:00:00-05:00", "2026-03-03T03:00:00-05:00"}},
+		{"*/20 * * * * *", "2026-03-01T10:00:00-05:00", []string{"2026-03-01T10:00:20-05:00", "2026-03-01T10:00:40-05:00", "2026-03-01T10:01:00-05:00"}},
+		{"30 9 * * mon-fri", "2026-03-06T10:00:00-05:00", []string{"2026-03-09T09:30:00-04:00", "2026-03-10T09:30:00-04:00"}},
+		{"0 0 1,15 * 0", "2026-02-28T12:00:00-05:00", []string{"2026-03-01T00:00:00-05:00", "2026-03-08T00:00:00-05:00", "2026-03-15T00:00:00-04:00"}},
+		{"0 12 * * 7", "2026-03-02T00:00:00-05:00", []string{"2026-03-08T12:00:00-04:00"}},
+		{"0 0 29 2 *", "2026-01-01T00:00:00-05:00", []string{"2028-02-29T00:00:00-05:00"}},
+		{"@hourly", "2026-03-01T10:30:00-05:00", []string{"2026-03-01T11:00:00-05:00"}},
+		{"@monthly", "2026-03-01T10:30:00-05:00", []string{"2026-04-01T00:00:00-04:00"}},
+		{"CRON_TZ=UTC 0 3 * * *", "2026-03-01T10:00:00-05:00", []string{"2026-03-01T22:00:00-05:00"}},
+
+		// Clocks go forward at 2:00, job in skipped hour runs at transition.
+		{"30 2 * * *", "2026-03-07T12:00:00-05:00", []string{"2026-03-08T03:00:00-04:00", "2026-03-09T02:30:00-04:00"}},
+		{"0 * * * *", "2026-03-08T00:30:00-05:00", []string{"2026-03-08T01:00:00-05:00", "2026-03-08T03:00:00-04:00"}},
+		// Clocks go back at 2:00, fixed hour job runs once, wildcard hour job
+		// runs in both repeated hours.
+		{"30 1 * * *", "2026-10-31T12:00:00-04:00", []string{"2026-11-01T01:30:00-04:00", "2026-11-02T01:30:00-05:00"}},
+		{"30 * * * *", "2026-11-01T00:45:00-04:00", []string{"2026-11-01T01:30:00-04:00", "2026-11-01T01:30:00-05:00", "2026-11-01T02:30:00-05:00"}},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.spec)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.spec, err)
+		}
+		from, err := time.Parse(time.RFC3339, tt.from)
+		if err != nil {
+			t.Fatal(err)
+		}
+		next := from.In(ny)
+		for _, w := range tt.want {
+			want, err := time.Parse(time.RFC3339, w)
+			if err != nil {
+				t.Fatal(err)
+			}
+			next = s.Next(next)
+			if !next.Equal(want) {
+				t.Errorf("%q: expected %s, got %s", tt.spec, want, next)
+				break
+			}
+			if next.Location() != ny {
+				t.Errorf("%q: expected result in %s, got %s", tt.spec, ny, next.Location())
+			}
+		}
+	}
+}
+
+func TestNextNever(t *testing.T) {
+	s, err := Parse("0 0 30 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next := s.Next(time.Now()); !next.IsZero() {
+		t.Fatalf("expected no next run, got %s", next)
+	}
+}
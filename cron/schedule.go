@@ -0,0 +1,137 @@
+package cron
+
+import "time"
+
+// Schedule is a parsed cron expression.
+type Schedule struct {
+	second, minute, hour, dom, month, dow uint64
+	loc                                   *time.Location
+}
+
+// Next returns first activation time after t or zero time if there is none
+// within five years.
+//
+// Activations follow wall clock of schedule location. When clocks go forward
+// and skip activation of a job with fixed hour, it runs at the transition.
+// When clocks go back and repeat an hour, job with fixed hour runs once, jobs
+// with wildcard hour run in both repeated hours.
+func (s *Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	if s.loc != nil {
+		t = t.In(s.loc)
+	}
+
+	// Start at the next whole second.
+	t = t.Add(time.Second - time.Duration(t.Nanosecond()))
+	fixedHour := s.hour&starBit == 0
+	added := false
+	yearLimit := t.Year() + 5
+
+wrap:
+	if t.Year() > yearLimit {
+		return time.Time{}
+	}
+
+	for 1<<uint(t.Month())&s.month == 0 {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+		}
+		t = t.AddDate(0, 1, 0)
+		if t.Month() == time.January {
+			goto wrap
+		}
+	}
+
+	for !s.dayMatches(t) {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+		}
+		t = startOfDay(t.AddDate(0, 0, 1))
+		if t.Day() == 1 {
+			goto wrap
+		}
+	}
+
+	for 1<<uint(t.Hour())&s.hour == 0 {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+		}
+		prev := t
+		t = t.Add(time.Hour)
+		if fixedHour && s.skippedHourMatches(prev, t) {
+			// Clocks went forward over activation hour.
+			return t.In(loc)
+		}
+		if t.Hour() == 0 {
+			goto wrap
+		}
+	}
+
+	for 1<<uint(t.Minute())&s.minute == 0 {
+		if !added {
+			added = true
+			t = t.Truncate(time.Minute)
+		}
+		t = t.Add(time.Minute)
+		if t.Minute() == 0 {
+			goto wrap
+		}
+	}
+
+	for 1<<uint(t.Second())&s.second == 0 {
+		if !added {
+			added = true
+			t = t.Truncate(time.Second)
+		}
+		t = t.Add(time.Second)
+		if t.Second() == 0 {
+			goto wrap
+		}
+	}
+
+	if fixedHour && t.Add(-time.Hour).Hour() == t.Hour() {
+		// Second pass through hour repeated when clocks went back.
+		t = t.Add(time.Second)
+		added = false
+		goto wrap
+	}
+	return t.In(loc)
+}
+
+// dayMatches reports whether day of month or day of week matches. If both
+// fields are restricted, either of them has to match, as in Vixie cron.
+func (s *Schedule) dayMatches(t time.Time) bool {
+	domMatch := 1<<uint(t.Day())&s.dom != 0
+	dowMatch := 1<<uint(t.Weekday())&s.dow != 0
+	if s.dom&starBit != 0 || s.dow&starBit != 0 {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// skippedHourMatches reports whether wall clock skipped an hour between prev
+// and next which matches hour field.
+func (s *Schedule) skippedHourMatches(prev, next time.Time) bool {
+	for h := prev.Hour() + 1; h < next.Hour(); h++ {
+		if 1<<uint(h)&s.hour != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// startOfDay returns the first instant of day, midnight may not exist when
+// clocks go forward at midnight.
+func startOfDay(t time.Time) time.Time {
+	t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	if t.Hour() != 0 {
+		t = t.Add(-time.Duration(t.Hour()) * time.Hour)
+		if t.Hour() != 0 {
+			t = t.Add(time.Hour)
+		}
+	}
+	return t
+}
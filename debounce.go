@@ -0,0 +1,247 @@
+package realtime
+
+import (
+	"sync"
+	"time"
+)
+
+// DebounceOption configures Debouncer or Throttler.
+type DebounceOption func(*debounceConfig)
+
+type debounceConfig struct {
+	leading  bool
+	trailing bool
+	maxWait  time.Duration
+}
+
+// WithLeadingEdge sets whether function is called on the first trigger.
+// Debouncer does not call it by default, Throttler does.
+func WithLeadingEdge(enabled bool) DebounceOption {
+	return func(c *debounceConfig) {
+		c.leading = enabled
+	}
+}
+
+// WithTrailingEdge sets whether function is called after triggers stop,
+// enabled by default.
+func WithTrailingEdge(enabled bool) DebounceOption {
+	return func(c *debounceConfig) {
+		c.trailing = enabled
+	}
+}
+
+// WithMaxWait limits how long Debouncer delays a call while it is triggered
+// continuously. Throttler ignores it.
+func WithMaxWait(d time.Duration) DebounceOption {
+	return func(c *debounceConfig) {
+		c.maxWait = d
+	}
+}
+
+// Debouncer calls function once triggers stop for a duration.
+type Debouncer struct {
+	mu    sync.Mutex
+	d     time.Duration
+	f     func()
+	cfg   debounceConfig
+	timer *Timer
+	// pending is set while triggers are debounced.
+	pending bool
+	// trailing is set if there was a trigger not followed by a call.
+	trailing bool
+	first    Time
+	deadline Time
+}
+
+// NewDebouncer returns Debouncer which calls f after d passes without
+// Trigger. Leading edge calls are made by Trigger caller, trailing edge calls
+// by timer callback.
+func NewDebouncer(d time.Duration, f func(), opts ...DebounceOption) *Debouncer {
+	db := &Debouncer{
+		d:   d,
+		f:   f,
+		cfg: debounceConfig{trailing: true},
+	}
+	for _, opt := range opts {
+		opt(&db.cfg)
+	}
+	return db
+}
+
+// Trigger postpones call of function.
+func (db *Debouncer) Trigger() {
+	db.mu.Lock()
+	now := Now()
+	call := false
+	if !db.pending {
+		db.pending = true
+		db.first = now
+		call = db.cfg.leading
+		db.trailing = !call
+	} else {
+		db.trailing = true
+	}
+	db.deadline = now.Add(db.d)
+	if db.cfg.maxWait > 0 {
+		if max := db.first.Add(db.cfg.maxWait); max.Before(db.deadline) {
+			db.deadline = max
+		}
+	}
+	db.timer = rearmFunc(db.timer, db.deadline.Sub(now), db.fire)
+	db.mu.Unlock()
+
+	if call {
+		db.f()
+	}
+}
+
+// Flush immediately makes pending trailing call.
+func (db *Debouncer) Flush() {
+	db.mu.Lock()
+	call := db.pending && db.trailing && db.cfg.trailing
+	db.reset()
+	db.mu.Unlock()
+
+	if call {
+		db.f()
+	}
+}
+
+// Cancel drops pending call.
+func (db *Debouncer) Cancel() {
+	db.mu.Lock()
+	db.reset()
+	db.mu.Unlock()
+}
+
+func (db *Debouncer) reset() {
+	db.pending = false
+	db.trailing = false
+	if db.timer != nil {
+		db.timer.Stop()
+	}
+}
+
+func (db *Debouncer) fire() {
+	db.mu.Lock()
+	if !db.pending || Now().Before(db.deadline) {
+		// Flushed, cancelled or triggered again after timer expired.
+		db.mu.Unlock()
+		return
+	}
+	call := db.trailing && db.cfg.trailing
+	db.pending = false
+	db.trailing = false
+	db.mu.Unlock()
+
+	if call {
+		db.f()
+	}
+}
+
+// Throttler calls function at most once per duration.
+type Throttler struct {
+	mu    sync.Mutex
+	d     time.Duration
+	f     func()
+	cfg   debounceConfig
+	timer *Timer
+	// window is set until d passes after the last call.
+	window    bool
+	windowEnd Time
+	trailing  bool
+}
+
+// NewThrottler returns Throttler which calls f on the first Trigger and then
+// at most once per d while it is triggered. Leading edge calls are made by
+// Trigger caller, trailing edge calls by timer callback.
+func NewThrottler(d time.Duration, f func(), opts ...DebounceOption) *Throttler {
+	th := &Throttler{
+		d:   d,
+		f:   f,
+		cfg: debounceConfig{leading: true, trailing: true},
+	}
+	for _, opt := range opts {
+		opt(&th.cfg)
+	}
+	return th
+}
+
+// Trigger calls function or schedules the call to the end of current window.
+func (th *Throttler) Trigger() {
+	th.mu.Lock()
+	if th.window {
+		th.trailing = true
+		th.mu.Unlock()
+		return
+	}
+	th.startWindow()
+	call := th.cfg.leading
+	th.trailing = !call
+	th.mu.Unlock()
+
+	if call {
+		th.f()
+	}
+}
+
+// Flush immediately makes pending trailing call.
+func (th *Throttler) Flush() {
+	th.mu.Lock()
+	call := th.window && th.trailing && th.cfg.trailing
+	th.trailing = false
+	if call {
+		th.startWindow()
+	}
+	th.mu.Unlock()
+
+	if call {
+		th.f()
+	}
+}
+
+// Cancel drops pending call and ends current window.
+func (th *Throttler) Cancel() {
+	th.mu.Lock()
+	th.window = false
+	th.trailing = false
+	if th.timer != nil {
+		th.timer.Stop()
+	}
+	th.mu.Unlock()
+}
+
+func (th *Throttler) startWindow() {
+	th.window = true
+	th.windowEnd = Now().Add(th.d)
+	th.timer = rearmFunc(th.timer, th.d, th.fire)
+}
+
+func (th *Throttler) fire() {
+	th.mu.Lock()
+	if !th.window || Now().Before(th.windowEnd) {
+		th.mu.Unlock()
+		return
+	}
+	if !th.trailing || !th.cfg.trailing {
+		th.window = false
+		th.trailing = false
+		th.mu.Unlock()
+		return
+	}
+	// Trailing call starts next window.
+	th.trailing = false
+	th.startWindow()
+	th.mu.Unlock()
+
+	th.f()
+}
+
+// rearmFunc re-arms t to call f after d, t is created on first use.
+func rearmFunc(t *Timer, d time.Duration, f func()) *Timer {
+	if t == nil {
+		return AfterFunc(d, f)
+	}
+	t.Reset(d)
+	return t
+}
@@ -0,0 +1,153 @@
+package realtime
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type callCounter struct {
+	n int32
+}
+
+func (c *callCounter) inc() {
+	atomic.AddInt32(&c.n, 1)
+}
+
+func (c *callCounter) expect(t *testing.T, n int32) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&c.n) != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d calls, got %d", n, atomic.LoadInt32(&c.n))
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// Make sure there are no more calls.
+	time.Sleep(5 * time.Millisecond)
+	if actual := atomic.LoadInt32(&c.n); actual != n {
+		t.Fatalf("expected %d calls, got %d", n, actual)
+	}
+}
+
+func TestDebouncer(t *testing.T) {
+	p, restore := useFakePoller()
+	defer restore()
+
+	var calls callCounter
+	db := NewDebouncer(time.Second, calls.inc)
+	for i := 0; i < 5; i++ {
+		db.Trigger()
+		p.advance(500 * time.Millisecond)
+	}
+	calls.expect(t, 0)
+	p.advance(500 * time.Millisecond)
+	calls.expect(t, 1)
+
+	db.Trigger()
+	db.Flush()
+	calls.expect(t, 2)
+	p.advance(time.Second)
+	calls.expect(t, 2)
+
+	db.Trigger()
+	db.Cancel()
+	p.advance(time.Second)
+	calls.expect(t, 2)
+}
+
+func TestDebouncerLeading(t *testing.T) {
+	p, restore := useFakePoller()
+	defer restore()
+
+	var calls callCounter
+	db := NewDebouncer(time.Second, calls.inc, WithLeadingEdge(true), WithTrailingEdge(false))
+	db.Trigger()
+	calls.expect(t, 1)
+	db.Trigger()
+	p.advance(time.Second)
+	calls.expect(t, 1)
+
+	db.Trigger()
+	calls.expect(t, 2)
+}
+
+func TestDebouncerMaxWait(t *testing.T) {
+	p, restore := useFakePoller()
+	defer restore()
+
+	var calls callCounter
+	db := NewDebouncer(time.Second, calls.inc, WithMaxWait(3*time.Second))
+	for i := 0; i < 7; i++ {
+		db.Trigger()
+		p.advance(500 * time.Millisecond)
+	}
+	calls.expect(t, 1)
+}
+
+func TestThrottler(t *testing.T) {
+	p, restore := useFakePoller()
+	defer restore()
+
+	var calls callCounter
+	th := NewThrottler(time.Second, calls.inc)
+	th.Trigger()
+	calls.expect(t, 1)
+	th.Trigger()
+	th.Trigger()
+	calls.expect(t, 1)
+	p.advance(time.Second)
+	calls.expect(t, 2)
+
+	// Trailing call started new window.
+	th.Trigger()
+	calls.expect(t, 2)
+	th.Flush()
+	calls.expect(t, 3)
+
+	th.Trigger()
+	th.Cancel()
+	p.advance(2 * time.Second)
+	calls.expect(t, 3)
+
+	th.Trigger()
+	calls.expect(t, 4)
+}
+
+func TestThrottlerNoTrailing(t *testing.T) {
+	p, restore := useFakePoller()
+	defer restore()
+
+	var calls callCounter
+	th := NewThrottler(time.Second, calls.inc, WithTrailingEdge(false))
+	th.Trigger()
+	th.Trigger()
+	p.advance(time.Second)
+	calls.expect(t, 1)
+	th.Trigger()
+	calls.expect(t, 2)
+}
+
+func TestDebouncerConcurrentTrigger(t *testing.T) {
+	var calls callCounter
+	db := NewDebouncer(20*time.Millisecond, calls.inc)
+	th := NewThrottler(time.Hour, calls.inc)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				db.Trigger()
+				th.Trigger()
+			}
+		}()
+	}
+	wg.Wait()
+	calls.expect(t, 1)
+	time.Sleep(40 * time.Millisecond)
+	calls.expect(t, 2)
+	th.Cancel()
+}
@@ -0,0 +1,99 @@
+// Package debug serves live realtime timers and tickers over HTTP.
+//
+// The package is typically only imported for the side effect of registering
+// its handler at /debug/realtime, the same way as net/http/pprof:
+//
+//	import _ "github.com/anjmao/realtime/debug"
+//
+// Handler renders HTML table by default and JSON when requested with
+// ?format=json query or Accept: application/json header.
+package debug
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/anjmao/realtime"
+)
+
+func init() {
+	http.Handle("/debug/realtime", Handler())
+}
+
+// Handler returns http handler listing live timers and tickers.
+func Handler() http.Handler {
+	return http.HandlerFunc(serve)
+}
+
+type entry struct {
+	ID          uint64 `json:"id"`
+	Kind        string `json:"kind"`
+	Clock       string `json:"clock"`
+	PeriodNs    int64  `json:"period_ns"`
+	DeadlineNs  int64  `json:"deadline_ns"`
+	RemainingNs int64  `json:"remaining_ns"`
+	Fires       uint64 `json:"fires"`
+	Missed      uint64 `json:"missed"`
+	Stack       string `json:"stack,omitempty"`
+}
+
+func serve(w http.ResponseWriter, r *http.Request) {
+	timers := realtime.Timers()
+
+	if r.URL.Query().Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json") {
+		entries := make([]entry, 0, len(timers))
+		for _, t := range timers {
+			entries = append(entries, entry{
+				ID:          t.ID,
+				Kind:        t.Kind,
+				Clock:       t.Clock,
+				PeriodNs:    int64(t.Period),
+				DeadlineNs:  int64(t.Deadline.Nano()),
+				RemainingNs: int64(t.Remaining),
+				Fires:       t.Fires,
+				Missed:      t.Missed,
+				Stack:       t.Stack,
+			})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(entries)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := page.Execute(w, timers); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+var page = template.Must(template.New("realtime").Funcs(template.FuncMap{
+	"duration": func(d time.Duration) string {
+		if d == 0 {
+			return "-"
+		}
+		return d.String()
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>/debug/realtime</title>
+<style>
+table { border-collapse: collapse; font-family: monospace; }
+td, th { border: 1px solid #ccc; padding: 2px 6px; text-align: left; vertical-align: top; }
+pre { margin: 0; }
+</style>
+</head>
+<body>
+<p>{{len .}} live timers and tickers. <a href="?format=json">json</a></p>
+<table>
+<tr><th>ID</th><th>Kind</th><th>Clock</th><th>Period</th><th>Deadline</th><th>Remaining</th><th>Fires</th><th>Missed</th><th>Stack</th></tr>
+{{range .}}<tr><td>{{.ID}}</td><td>{{.Kind}}</td><td>{{.Clock}}</td><td>{{duration .Period}}</td><td>{{.Deadline}}</td><td>{{duration .Remaining}}</td><td>{{.Fires}}</td><td>{{.Missed}}</td><td>{{if .Stack}}<pre>{{.Stack}}</pre>{{end}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
@@ -0,0 +1,51 @@
+package debug
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anjmao/realtime"
+)
+
+func TestHandlerJSON(t *testing.T) {
+	ticker := realtime.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/realtime?format=json", nil))
+
+	var entries []entry
+	if err := json.Unmarshal(rec.Body.Bytes(), &entries); err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, e := range entries {
+		if e.Kind == "ticker" && e.PeriodNs == int64(time.Hour) {
+			found = true
+			if e.RemainingNs <= 0 || e.RemainingNs > int64(time.Hour) {
+				t.Fatalf("unexpected remaining time %d", e.RemainingNs)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("expected ticker in %s", rec.Body.String())
+	}
+}
+
+func TestHandlerHTML(t *testing.T) {
+	timer := realtime.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/realtime", nil))
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Fatalf("expected html content type, got %s", ct)
+	}
+	if !strings.Contains(rec.Body.String(), "<td>timer</td>") {
+		t.Fatalf("expected timer row in %s", rec.Body.String())
+	}
+}
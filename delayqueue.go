@@ -0,0 +1,233 @@
+package realtime
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/anjmao/realtime/internal/deadlineheap"
+)
+
+// DelayQueue releases items on channel C once their delay passes, in
+// deadline order. Items with equal deadline are released in order they were
+// put.
+type DelayQueue struct {
+	// C receives due items, it is closed by Close.
+	C <-chan interface{}
+
+	mu    sync.Mutex
+	out   chan interface{}
+	items *deadlineheap.Heap
+	timer *Timer
+	head  deadlineheap.Elem // item timer is armed for
+	send  *delaySend        // due item being sent to C
+	// changed wakes up dispatch when head changes.
+	changed chan struct{}
+	closed  bool
+	done    chan struct{}
+	exited  chan struct{}
+}
+
+// DelayHandle identifies item put to DelayQueue.
+type DelayHandle struct {
+	e delayed
+}
+
+type delayed struct {
+	deadlineheap.Item
+	item     interface{}
+	deadline Time
+}
+
+// delaySend is a send of due item to C. Remove of the item aborts the send
+// and waits until dispatch decides whether item was received or removed.
+type delaySend struct {
+	e       deadlineheap.Elem
+	abort   chan struct{}
+	aborted bool
+	done    chan struct{}
+	removed bool
+}
+
+func delayedBefore(a, b deadlineheap.Elem) bool {
+	return a.(*delayed).deadline.Before(b.(*delayed).deadline)
+}
+
+// NewDelayQueue returns empty queue. Close must be called to release it.
+func NewDelayQueue() *DelayQueue {
+	out := make(chan interface{})
+	q := &DelayQueue{
+		C:       out,
+		out:     out,
+		items:   deadlineheap.New(delayedBefore),
+		timer:   newTimer(time.Hour),
+		changed: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		exited:  make(chan struct{}),
+	}
+	q.timer.Stop()
+	go q.dispatch()
+	return q
+}
+
+// Put adds item which is released after delay.
+func (q *DelayQueue) Put(item interface{}, delay time.Duration) *DelayHandle {
+	return q.PutAt(item, Now().Add(delay))
+}
+
+// PutAt adds item which is released at deadline. It panics if queue is
+// closed.
+func (q *DelayQueue) PutAt(item interface{}, deadline Time) *DelayHandle {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		panic(errors.New("realtime: put to closed DelayQueue"))
+	}
+	h := &DelayHandle{e: delayed{item: item, deadline: deadline}}
+	q.items.Push(&h.e)
+	q.rearm()
+	return h
+}
+
+// Remove removes item which was not released yet and reports whether it was
+// removed. Due item is released only once it is received, so it can be
+// removed while it waits for receiver. Remove returns false once item was
+// received.
+func (q *DelayQueue) Remove(h *DelayHandle) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if s := q.send; s != nil && s.e == &h.e {
+		if !s.aborted {
+			s.aborted = true
+			close(s.abort)
+		}
+		q.mu.Unlock()
+		<-s.done
+		q.mu.Lock()
+		return s.removed
+	}
+	if !q.items.Remove(&h.e) {
+		return false
+	}
+	q.rearm()
+	return true
+}
+
+// Len returns number of items which were not released yet. Item is counted
+// until dispatch notices it was received.
+func (q *DelayQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.items.Len()
+}
+
+// Close stops the queue and closes C. If drain is true, remaining items are
+// sent to C in deadline order without waiting for their deadlines and Close
+// blocks until they are received. Otherwise remaining items are returned in
+// deadline order.
+func (q *DelayQueue) Close(drain bool) []interface{} {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return nil
+	}
+	q.closed = true
+	q.timer.Stop()
+	q.mu.Unlock()
+
+	close(q.done)
+	<-q.exited
+
+	var remaining []interface{}
+	q.mu.Lock()
+	for q.items.Len() > 0 {
+		remaining = append(remaining, q.items.Pop().(*delayed).item)
+	}
+	q.mu.Unlock()
+
+	if drain {
+		for _, item := range remaining {
+			q.out <- item
+		}
+		remaining = nil
+	}
+	close(q.out)
+	return remaining
+}
+
+// rearm must be called with mu held. It re-arms timer if head of queue
+// changed.
+func (q *DelayQueue) rearm() {
+	head := q.items.Peek()
+	if head == nil {
+		if q.head != nil {
+			q.head = nil
+			select {
+			case q.changed <- struct{}{}:
+			default:
+			}
+		}
+		return
+	}
+	if head != q.head {
+		q.head = head
+		q.timer.Reset(head.(*delayed).deadline.Sub(Now()))
+		select {
+		case q.changed <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (q *DelayQueue) dispatch() {
+	defer close(q.exited)
+	for {
+		q.mu.Lock()
+		head := q.items.Peek()
+		due := head != nil && !head.(*delayed).deadline.After(Now())
+		var send *delaySend
+		if due {
+			// Item stays queued until it is received, so it can be
+			// removed and is counted by Len meanwhile. Send is abandoned
+			// when head changes.
+			send = &delaySend{
+				e:     head,
+				abort: make(chan struct{}),
+				done:  make(chan struct{}),
+			}
+			q.send = send
+		}
+		q.mu.Unlock()
+		if !due {
+			select {
+			case <-q.timer.C:
+			case <-q.changed:
+			case <-q.done:
+				return
+			}
+			continue
+		}
+
+		sent, exit := false, false
+		select {
+		case q.out <- head.(*delayed).item:
+			sent = true
+		case <-send.abort:
+		case <-q.changed:
+		case <-q.done:
+			exit = true
+		}
+		q.mu.Lock()
+		if sent || send.aborted {
+			q.items.Remove(head)
+			send.removed = !sent
+			q.rearm()
+		}
+		q.send = nil
+		close(send.done)
+		q.mu.Unlock()
+		if exit {
+			return
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package realtime
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func expectItem(t *testing.T, q *DelayQueue, expected interface{}) {
+	t.Helper()
+	select {
+	case item := <-q.C:
+		if item != expected {
+			t.Fatalf("expected item %v, got %v", expected, item)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected item %v", expected)
+	}
+}
+
+func expectNoItem(t *testing.T, q *DelayQueue) {
+	t.Helper()
+	select {
+	case item := <-q.C:
+		t.Fatalf("unexpected item %v", item)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestDelayQueue(t *testing.T) {
+	p, restore := useFakePoller()
+	defer restore()
+
+	q := NewDelayQueue()
+	defer q.Close(false)
+	q.Put("c", 3*time.Second)
+	q.Put("a", time.Second)
+	q.PutAt("b", Now().Add(2*time.Second))
+	q.Put("a2", time.Second)
+	if q.Len() != 4 {
+		t.Fatalf("expected 4 items, got %d", q.Len())
+	}
+	// Single engine timer is armed for the head.
+	if n := len(p.timers()); n != 1 {
+		t.Fatalf("expected single engine timer, got %d", n)
+	}
+
+	expectNoItem(t, q)
+	p.advance(time.Second)
+	expectItem(t, q, "a")
+	expectItem(t, q, "a2")
+	expectNoItem(t, q)
+	p.advance(2 * time.Second)
+	expectItem(t, q, "b")
+	expectItem(t, q, "c")
+	// Received item is removed by dispatch right after it is received.
+	waitFor(t, func() bool { return q.Len() == 0 })
+}
+
+func TestDelayQueueRemove(t *testing.T) {
+	p, restore := useFakePoller()
+	defer restore()
+
+	q := NewDelayQueue()
+	defer q.Close(false)
+	a := q.Put("a", time.Second)
+	q.Put("b", 2*time.Second)
+	if !q.Remove(a) || q.Remove(a) {
+		t.Fatal("expected item to be removed once")
+	}
+	p.advance(time.Second)
+	expectNoItem(t, q)
+	p.advance(time.Second)
+	expectItem(t, q, "b")
+
+	// Earlier item put later re-arms timer.
+	q.Put("late", time.Hour)
+	q.Put("early", time.Minute)
+	p.advance(time.Minute)
+	expectItem(t, q, "early")
+}
+
+func TestDelayQueueNotReceived(t *testing.T) {
+	p, restore := useFakePoller()
+	defer restore()
+
+	q := NewDelayQueue()
+	defer q.Close(false)
+	a := q.Put("a", time.Second)
+	q.Put("b", time.Second)
+	q.Put("c", 2*time.Second)
+	p.advance(time.Second)
+	// Let dispatch block on sending due item.
+	time.Sleep(10 * time.Millisecond)
+	if q.Len() != 3 {
+		t.Fatalf("expected item waiting to be received to be counted, got %d items", q.Len())
+	}
+	if !q.Remove(a) {
+		t.Fatal("expected item waiting to be received to be removed")
+	}
+
+	// Earlier item put while due item waits is received first.
+	q.PutAt("early", Now().Add(-time.Second))
+	expectItem(t, q, "early")
+	expectItem(t, q, "b")
+	expectNoItem(t, q)
+	p.advance(time.Second)
+	expectItem(t, q, "c")
+}
+
+func TestDelayQueueRemoveReceived(t *testing.T) {
+	q := NewDelayQueue()
+	defer q.Close(false)
+
+	// Due item is either received or removed, never both.
+	for i := 0; i < 1000; i++ {
+		h := q.PutAt(i, Now())
+		removed := make(chan bool, 1)
+		go func(spins int) {
+			// Vary the moment of Remove relative to the send.
+			for j := 0; j < spins; j++ {
+				runtime.Gosched()
+			}
+			removed <- q.Remove(h)
+		}(i % 100)
+		select {
+		case item := <-q.C:
+			if item != i {
+				t.Fatalf("expected item %d, got %v", i, item)
+			}
+			if <-removed {
+				t.Fatalf("item %d was removed after it was received", i)
+			}
+		case r := <-removed:
+			if !r {
+				t.Fatalf("item %d was neither removed nor received", i)
+			}
+		}
+	}
+}
+
+func TestDelayQueueClose(t *testing.T) {
+	q := NewDelayQueue()
+	q.Put("b", 2*time.Hour)
+	q.Put("a", time.Hour)
+	q.Put("now", 0)
+	// Wait until due item is dispatched, but not received.
+	time.Sleep(10 * time.Millisecond)
+
+	remaining := q.Close(false)
+	if len(remaining) != 3 || remaining[0] != "now" || remaining[1] != "a" || remaining[2] != "b" {
+		t.Fatalf("expected remaining items in deadline order, got %v", remaining)
+	}
+	if _, ok := <-q.C; ok {
+		t.Fatal("expected closed channel")
+	}
+	if q.Close(false) != nil {
+		t.Fatal("expected no items on second Close")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic on put to closed queue")
+		}
+	}()
+	q.Put("c", 0)
+}
+
+func TestDelayQueueDrain(t *testing.T) {
+	q := NewDelayQueue()
+	q.Put("b", 2*time.Hour)
+	q.Put("a", time.Hour)
+
+	var items []interface{}
+	done := make(chan struct{})
+	go func() {
+		for item := range q.C {
+			items = append(items, item)
+		}
+		close(done)
+	}()
+	if remaining := q.Close(true); remaining != nil {
+		t.Fatalf("expected no remaining items, got %v", remaining)
+	}
+	<-done
+	if len(items) != 2 || items[0] != "a" || items[1] != "b" {
+		t.Fatalf("expected drained items in deadline order, got %v", items)
+	}
+}
@@ -0,0 +1,104 @@
+package realtime
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// poller is implemented by platform specific timer backends.
+type poller interface {
+	// registerEvent arms event to expire after d and then every period for
+	// tickers.
+	registerEvent(id uint64, kind eventKind, d, period time.Duration, handler timerHandler) error
+	deleteEvent(id uint64) error
+	// remaining returns time left until next expiration of event.
+	remaining(id uint64) (time.Duration, error)
+	timers() []TimerInfo
+	// close stops poller and releases its fds and remaining events.
+	close()
+}
+
+// engine holds enginePoller, it is replaced in tests.
+var engine atomic.Value
+
+type enginePoller struct {
+	poller
+}
+
+func getEngine() poller {
+	startEngine()
+	return engine.Load().(enginePoller).poller
+}
+
+func setEngine(p poller) {
+	engine.Store(enginePoller{p})
+}
+
+// startEngine creates platform pollers on first use, so the number of shards
+// can be configured before. It holds configMu, so WithShards sees either
+// started engine or shard count it was started with.
+func startEngine() {
+	if engineStarted() {
+		return
+	}
+	configMu.Lock()
+	defer configMu.Unlock()
+	if engineStarted() {
+		return
+	}
+	n := getConfig().shards
+	if n <= 1 {
+		setEngine(newPoller())
+	} else {
+		s := make(shards, n)
+		for i := range s {
+			s[i] = newPoller()
+		}
+		setEngine(s)
+	}
+}
+
+func engineStarted() bool {
+	return engine.Load() != nil
+}
+
+// eachPoller calls f for every shard.
+func eachPoller(f func(p poller)) {
+	p := getEngine()
+	if s, ok := p.(shards); ok {
+		for _, p := range s {
+			f(p)
+		}
+		return
+	}
+	f(p)
+}
+
+// clockNow returns current time of engine clock. It is replaced together with
+// engine in tests.
+var clockNow = nanotime
+
+// armEvent registers new event or re-arms existing one.
+func armEvent(id uint64, kind eventKind, d, period time.Duration, handler timerHandler) {
+	if err := getEngine().registerEvent(id, kind, d, period, handler); err != nil {
+		panic(err)
+	}
+}
+
+func stopEvent(id uint64) {
+	if err := getEngine().deleteEvent(id); err != nil {
+		panic(err)
+	}
+}
+
+func remainingEvent(id uint64) time.Duration {
+	d, err := getEngine().remaining(id)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func liveTimers() []TimerInfo {
+	return coalesced.expand(getEngine().timers())
+}
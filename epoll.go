@@ -3,10 +3,9 @@
 package realtime
 
 import (
-	"errors"
 	"fmt"
-	"os"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 	"unsafe"
@@ -17,134 +16,220 @@ import (
 // TODO: Add retries for syscalls.
 // TODO: Make sure resources are released on exit.
 
-type timerHandler func()
-
 type epoll struct {
 	fd int
 	// eventFd int
 
-	handlers   map[int]timerHandler
-	handlersMu sync.RWMutex
-	logger     func(msg ...interface{})
+	events   map[uint64]*event
+	eventsMu sync.Mutex
+	// closing is set by close handler on poll goroutine.
+	closing bool
+	exited  chan struct{}
 }
 
 func newEpoll() (*epoll, error) {
 	fd, err := unix.EpollCreate1(0)
 	if err != nil {
+		logger().Log(LevelError, "epoll_create1 failed", "backend", "epoll", "err", err)
 		return nil, fmt.Errorf("could not create epoll: %v", err)
 	}
 
-	logger := func(msg ...interface{}) {}
-	if os.Getenv("EPOLL_DEBUG") == "1" {
-		logger = func(msg ...interface{}) {
-			fmt.Println("epoll:", msg)
-		}
-	}
-
 	ep := &epoll{
-		fd:       fd,
-		handlers: map[int]timerHandler{},
-		logger:   logger,
+		fd:     fd,
+		events: map[uint64]*event{},
+		exited: make(chan struct{}),
 	}
+	atomic.AddInt64(&stats.fdsInUse, 1)
+	ep.log(LevelDebug, "epoll created", "fd", fd)
 
 	return ep, nil
 }
 
-func (ep *epoll) registerTimerEvent(d time.Duration, handler timerHandler) (int, error) {
-	ep.logger("registerTimerEvent enter")
-	tfd, err := ep.createTimer(d)
+func (ep *epoll) log(level Level, msg string, keyvals ...interface{}) {
+	logger().Log(level, msg, append([]interface{}{"backend", "epoll"}, keyvals...)...)
+}
 
-	ep.handlersMu.Lock()
-	if _, ok := ep.handlers[tfd]; ok {
-		return 0, fmt.Errorf("timer event %d is already registered", tfd)
-	}
-	ep.handlers[tfd] = handler
-	ep.handlersMu.Unlock()
+func (ep *epoll) eventsLen() int {
+	ep.eventsMu.Lock()
+	defer ep.eventsMu.Unlock()
+	return len(ep.events)
+}
 
-	ev := &unix.EpollEvent{
-		Events: unix.EPOLLIN | unix.EPOLLONESHOT,
-		Fd:     int32(tfd),
+func (ep *epoll) timers() []TimerInfo {
+	now := int64(nanotime())
+	ep.eventsMu.Lock()
+	defer ep.eventsMu.Unlock()
+	infos := make([]TimerInfo, 0, len(ep.events))
+	for _, e := range ep.events {
+		infos = append(infos, e.info(now))
 	}
-	err = unix.EpollCtl(ep.fd, unix.EPOLL_CTL_ADD, tfd, ev)
-	if err != nil {
-		return 0, fmt.Errorf("could not create one time event %d: %v", tfd, err)
-	}
-	ep.logger("registerTimerEvent done", tfd)
-	return tfd, nil
+	return infos
+}
+
+func (ep *epoll) registerTimerEvent(d time.Duration, handler timerHandler) (uint64, error) {
+	id := nextEventID()
+	return id, ep.registerEvent(id, timerEvent, d, 0, handler)
 }
 
-func (ep *epoll) registerTickerEvent(d time.Duration, handler timerHandler) (int, error) {
-	ep.logger("registerTickerEvent enter")
-	tfd, err := ep.createTimer(d)
+func (ep *epoll) registerTickerEvent(d time.Duration, handler timerHandler) (uint64, error) {
+	id := nextEventID()
+	return id, ep.registerEvent(id, tickerEvent, d, d, handler)
+}
 
-	ep.handlersMu.Lock()
-	if _, ok := ep.handlers[tfd]; ok {
-		return 0, fmt.Errorf("periodic event %d is already registered", tfd)
+// registerEvent arms event with given id. Already registered event is re-armed
+// in place reusing its timer fd.
+func (ep *epoll) registerEvent(id uint64, kind eventKind, d, period time.Duration, handler timerHandler) error {
+	e := newEvent(id, kind, d, period, handler)
+
+	ep.eventsMu.Lock()
+	if old, ok := ep.events[id]; ok {
+		defer ep.eventsMu.Unlock()
+		e.fd, e.clock, e.created = old.fd, old.clock, old.created
+		e.fires, e.missed = old.fires, old.missed
+		if err := ep.setTimer(e.fd, d, e.period); err != nil {
+			return fmt.Errorf("could not reset %s event %d: %v", kind, id, err)
+		}
+		ep.events[id] = e
+		stats.eventRemoved(old.kind)
+		stats.eventAdded(kind)
+		if logEnabled(LevelDebug) {
+			ep.log(LevelDebug, kind.String()+" reset", "id", id, "fd", e.fd, "duration", d)
+		}
+		return nil
 	}
-	ep.handlers[tfd] = handler
-	ep.handlersMu.Unlock()
+	ep.eventsMu.Unlock()
 
+	tfd, clock, err := ep.createTimer(d, e.period)
+	if err != nil {
+		return err
+	}
+	e.fd = tfd
+	e.clock = clock
+
+	ep.eventsMu.Lock()
+	ep.events[id] = e
+	stats.eventAdded(kind)
+	ep.eventsMu.Unlock()
+
+	// Event data carries 64 bit event id split into Fd and Pad.
 	ev := &unix.EpollEvent{
 		Events: unix.EPOLLIN,
-		Fd:     int32(tfd),
+		Fd:     int32(id),
+		Pad:    int32(id >> 32),
 	}
-	err = unix.EpollCtl(ep.fd, unix.EPOLL_CTL_ADD, tfd, ev)
-	if err != nil {
-		return 0, fmt.Errorf("could not create periodic event %d: %v", tfd, err)
+	if err := unix.EpollCtl(ep.fd, unix.EPOLL_CTL_ADD, tfd, ev); err != nil {
+		ep.log(LevelError, "epoll_ctl add failed", "id", id, "fd", tfd, "err", err)
+		ep.deleteEvent(id)
+		return fmt.Errorf("could not create %s event %d: %v", kind, id, err)
+	}
+	if logEnabled(LevelDebug) {
+		ep.log(LevelDebug, kind.String()+" registered", "id", id, "fd", tfd, "duration", d)
 	}
-	ep.logger("registerTickerEvent done", tfd)
-	return tfd, nil
+	return nil
 }
 
-func (ep *epoll) deleteEvent(id int) error {
-	ep.handlersMu.Lock()
-	if _, ok := ep.handlers[id]; !ok {
-		ep.handlersMu.Unlock()
+func (ep *epoll) deleteEvent(id uint64) error {
+	ep.eventsMu.Lock()
+	e, ok := ep.events[id]
+	if !ok {
+		ep.eventsMu.Unlock()
 		return nil
 	}
-	delete(ep.handlers, id)
-	ep.handlersMu.Unlock()
+	delete(ep.events, id)
+	stats.eventRemoved(e.kind)
+	ep.eventsMu.Unlock()
 
-	if err := unix.EpollCtl(ep.fd, unix.EPOLL_CTL_DEL, id, nil); err != nil {
+	// Closing timer fd also removes it from epoll interest list.
+	if err := ep.closeTimer(e.fd); err != nil {
 		return fmt.Errorf("could not delete event %d: %v", id, err)
 	}
 	return nil
 }
 
-func (ep *epoll) resetTimerEvent(id int, d time.Duration) error {
-	// TODO: Reset flow could be:
-	// 1. Close old timer and create new.
-	// 2. Call EpollCtl EPOLL_CTL_MOD with new event and timer fd.
+func (ep *epoll) remaining(id uint64) (time.Duration, error) {
+	ep.eventsMu.Lock()
+	defer ep.eventsMu.Unlock()
+	e, ok := ep.events[id]
+	if !ok {
+		return 0, nil
+	}
+	var spec timerSpec
+	if err := timerFdGetTime(e.fd, &spec); err != nil {
+		ep.log(LevelError, "timerfd_gettime failed", "id", id, "fd", e.fd, "err", err)
+		return 0, fmt.Errorf("could not get timer %d: %v", id, err)
+	}
+	return time.Duration(spec.ItValue.Nano()), nil
+}
 
-	return errors.New("not implemented")
+// close makes poll goroutine exit and waits until it released epoll and
+// remaining timer fds.
+func (ep *epoll) close() {
+	err := ep.registerEvent(nextEventID(), timerEvent, 0, 0, func(uint64, uint64) {
+		ep.closing = true
+	})
+	if err != nil {
+		return
+	}
+	<-ep.exited
 }
 
-func (ep *epoll) createTimer(d time.Duration) (int, error) {
+func (ep *epoll) createTimer(d, period time.Duration) (int, string, error) {
+	clock := "CLOCK_BOOTTIME"
 	tfd, err := timerFdCreate(unix.CLOCK_BOOTTIME, unix.O_NONBLOCK)
 	if err != nil {
-		ep.logger("fallback to CLOCK_MONOTONIC")
+		ep.log(LevelWarn, "clock fallback", "from", "CLOCK_BOOTTIME", "to", "CLOCK_MONOTONIC", "err", err)
+		clock = "CLOCK_MONOTONIC"
 		tfd, err = timerFdCreate(unix.CLOCK_MONOTONIC, unix.O_NONBLOCK)
 		if err != nil {
-			return 0, fmt.Errorf("could not create timer file descriptor: %v", err)
+			ep.log(LevelError, "timerfd_create failed", "err", err)
+			return 0, "", fmt.Errorf("could not create timer file descriptor: %v", err)
 		}
 	}
 
-	val := unix.Timespec{Sec: int64(0), Nsec: d.Nanoseconds()}
-	if val.Nsec >= 1e9 {
-		val.Sec = val.Nsec / 1e9
-		val.Nsec = val.Nsec % 1e9
+	if err := ep.setTimer(tfd, d, period); err != nil {
+		unix.Close(tfd)
+		return 0, "", fmt.Errorf("could not set timer: %v", err)
 	}
-	spec := timerSpec{
-		ItInterval: val,
-		ItValue:    val,
+	n := atomic.AddInt64(&stats.fdsInUse, 1)
+	if logEnabled(LevelDebug) {
+		ep.log(LevelDebug, "timerfd created", "fd", tfd, "clock", clock, "fds", n)
 	}
 
+	return tfd, clock, nil
+}
+
+// setTimer arms timer fd to expire after d and then every period if period is
+// not zero. Setting new value also resets pending expirations count.
+func (ep *epoll) setTimer(tfd int, d, period time.Duration) error {
+	// Zero value disarms timer, so fire expired timers as soon as possible.
+	if d <= 0 {
+		d = 1
+	}
+	spec := timerSpec{
+		ItInterval: durationToTimespec(period),
+		ItValue:    durationToTimespec(d),
+	}
 	if err := timerFdSetTime(tfd, 0, &spec, &timerSpec{}); err != nil {
-		unix.Close(tfd)
-		return 0, fmt.Errorf("could not set timer: %v", err)
+		ep.log(LevelError, "timerfd_settime failed", "fd", tfd, "err", err)
+		return err
 	}
+	return nil
+}
 
-	return tfd, nil
+func (ep *epoll) closeTimer(tfd int) error {
+	if err := unix.Close(tfd); err != nil {
+		ep.log(LevelError, "close timerfd failed", "fd", tfd, "err", err)
+		return err
+	}
+	n := atomic.AddInt64(&stats.fdsInUse, -1)
+	if logEnabled(LevelDebug) {
+		ep.log(LevelDebug, "timerfd closed", "fd", tfd, "fds", n)
+	}
+	return nil
+}
+
+func durationToTimespec(d time.Duration) unix.Timespec {
+	return unix.NsecToTimespec(d.Nanoseconds())
 }
 
 func (ep *epoll) poll(onError func(error)) {
@@ -154,19 +239,31 @@ func (ep *epoll) poll(onError func(error)) {
 	)
 
 	defer func() {
+		ep.eventsMu.Lock()
+		for id, e := range ep.events {
+			delete(ep.events, id)
+			stats.eventRemoved(e.kind)
+			ep.closeTimer(e.fd)
+		}
+		ep.eventsMu.Unlock()
+		atomic.AddInt64(&stats.fdsInUse, -1)
 		if err := unix.Close(ep.fd); err != nil {
+			ep.log(LevelError, "close epoll failed", "fd", ep.fd, "err", err)
 			onError(err)
 		}
+		close(ep.exited)
 	}()
 
 	events := make([]unix.EpollEvent, eventsLen)
-	tickerBuf := make([]byte, 256)
+	fired := make([]*event, 0, eventsLen)
+	expirationsBuf := make([]byte, 8)
 	for {
 		n, err := unix.EpollWait(ep.fd, events, -1)
 		if err != nil {
 			if temporaryErr(err) {
 				continue
 			}
+			ep.log(LevelError, "epoll_wait failed", "err", err)
 			onError(err)
 			return
 		}
@@ -174,32 +271,65 @@ func (ep *epoll) poll(onError func(error)) {
 		if n == 0 {
 			continue
 		}
+		stats.polled(n, len(events))
 
-		// TODO: Lock only for call handlers. Could register pending handlers into separate
-		// TODO: slice and call outside lock.
-		ep.handlersMu.RLock()
+		now := int64(nanotime())
+		fired = fired[:0]
+		wakeup := int64(-1)
+		ep.eventsMu.Lock()
 		for i := 0; i < n; i++ {
-			e := events[i]
-			handler := ep.handlers[int(e.Fd)]
-			if handler != nil {
-				handler()
+			id := uint64(uint32(events[i].Fd)) | uint64(uint32(events[i].Pad))<<32
+			e, ok := ep.events[id]
+			if !ok {
+				// Event was deleted after it fired.
+				continue
 			}
 
-			if e.Events&unix.EPOLLONESHOT != 0 {
-				// Remove handler for one shot timer.
-				delete(ep.handlers, int(e.Fd))
+			// Read expirations count. Timer could be re-armed after
+			// epoll_wait returned in which case there is nothing to read.
+			var expirations uint64 = 1
+			if _, err := unix.Read(e.fd, expirationsBuf); err != nil {
+				if err == unix.EAGAIN {
+					continue
+				}
+				ep.log(LevelWarn, "timerfd read failed", "id", id, "fd", e.fd, "err", err)
 			} else {
-				// Read periodic ticker.
-				if _, err := unix.Read(int(e.Fd), tickerBuf); err != nil {
-					// TODO: Will probably need to use tickerBuf and return read result to handler.
-					// TODO: Handle error?
+				expirations = *(*uint64)(unsafe.Pointer(&expirationsBuf[0]))
+			}
+			if e.kind == timerEvent {
+				// Remove and release one shot timer.
+				delete(ep.events, id)
+				stats.eventRemoved(e.kind)
+				ep.closeTimer(e.fd)
+			}
+			if late := stats.fired(e, now); wakeup < 0 || late < wakeup {
+				wakeup = late
+			}
+			if missed := e.expire(expirations); missed > 0 {
+				atomic.AddUint64(&stats.tickerOverruns, missed)
+				if logEnabled(LevelWarn) {
+					ep.log(LevelWarn, "ticker overrun", "id", id, "missed", missed)
 				}
 			}
+			fired = append(fired, e)
+		}
+		ep.eventsMu.Unlock()
+		if wakeup >= 0 {
+			stats.woke(wakeup)
+		}
+
+		for _, e := range fired {
+			callHandler(e)
+		}
+		if ep.closing {
+			return
 		}
-		ep.handlersMu.RUnlock()
 
 		if n == len(events) && n*2 <= maxEventsLen {
 			events = make([]unix.EpollEvent, n*2)
+			if logEnabled(LevelDebug) {
+				ep.log(LevelDebug, "events buffer grown", "len", len(events))
+			}
 		}
 	}
 }
@@ -226,6 +356,14 @@ func timerFdSetTime(fd int, flags int, new *timerSpec, old *timerSpec) error {
 	return nil
 }
 
+func timerFdGetTime(fd int, curr *timerSpec) error {
+	_, _, err := unix.Syscall(unix.SYS_TIMERFD_GETTIME, uintptr(fd), uintptr(unsafe.Pointer(curr)), 0)
+	if err != 0 {
+		return err
+	}
+	return nil
+}
+
 func temporaryErr(err error) bool {
 	errno, ok := err.(syscall.Errno)
 	if !ok {
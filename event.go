@@ -0,0 +1,130 @@
+package realtime
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// timerHandler is called when event expires. Expirations is number of
+// expirations since previous call, it is greater than one for tickers which
+// missed ticks, e.g. during suspend.
+type timerHandler func(id, expirations uint64)
+
+var lastEventID uint64
+
+// nextEventID returns unique id for new event. Ids are never reused so stale
+// references to stopped or fired events are harmless.
+func nextEventID() uint64 {
+	return atomic.AddUint64(&lastEventID, 1)
+}
+
+type eventKind int
+
+const (
+	timerEvent eventKind = iota
+	tickerEvent
+)
+
+func (k eventKind) String() string {
+	if k == tickerEvent {
+		return "ticker"
+	}
+	return "timer"
+}
+
+// event is a timer or ticker registered in the poller.
+type event struct {
+	id       uint64
+	fd       int    // timer file descriptor, used by epoll only
+	ident    uint64 // kevent ident, used by kqueue only
+	kind     eventKind
+	clock    string
+	period   time.Duration
+	created  int64
+	deadline int64 // nanotime of next expiration
+	fires    uint64
+	missed   uint64
+	// expirations of last fire, passed to handler.
+	expirations uint64
+	handler     timerHandler
+}
+
+func newEvent(id uint64, kind eventKind, d, period time.Duration, handler timerHandler) *event {
+	if d <= 0 {
+		d = 1
+	}
+	now := int64(nanotime())
+	e := &event{
+		id:       id,
+		kind:     kind,
+		created:  now,
+		deadline: now + int64(d),
+		handler:  handler,
+	}
+	if kind == tickerEvent {
+		e.period = period
+	}
+	return e
+}
+
+// expire moves deadline of fired event forward by number of expirations and
+// returns missed ticks count.
+func (e *event) expire(expirations uint64) uint64 {
+	if expirations == 0 {
+		expirations = 1
+	}
+	if e.kind == tickerEvent {
+		e.deadline += int64(expirations) * int64(e.period)
+	}
+	e.fires++
+	e.missed += expirations - 1
+	e.expirations = expirations
+	return expirations - 1
+}
+
+// callHandler runs event handler isolating poller from its panics.
+func callHandler(e *event) {
+	defer recoverHandler(e.id, e.kind)
+	e.handler(e.id, e.expirations)
+}
+
+// TimerInfo describes live timer or ticker.
+type TimerInfo struct {
+	ID        uint64
+	Kind      string
+	Clock     string
+	Period    time.Duration
+	Created   Time
+	Deadline  Time
+	Remaining time.Duration
+	Fires     uint64
+	Missed    uint64
+	Stack     string
+}
+
+func (e *event) info(now int64) TimerInfo {
+	return TimerInfo{
+		ID:        e.id,
+		Kind:      e.kind.String(),
+		Clock:     e.clock,
+		Period:    e.period,
+		Created:   Time{ns: time.Duration(e.created)},
+		Deadline:  Time{ns: time.Duration(e.deadline)},
+		Remaining: time.Duration(e.deadline - now),
+		Fires:     e.fires,
+		Missed:    e.missed,
+	}
+}
+
+// Timers returns all live timers and tickers ordered by id.
+func Timers() []TimerInfo {
+	infos := liveTimers()
+	for i := range infos {
+		infos[i].Stack = trackedStack(infos[i].ID)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ID < infos[j].ID
+	})
+	return infos
+}
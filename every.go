@@ -0,0 +1,261 @@
+package realtime
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// EveryOption configures periodic job started with Every.
+type EveryOption func(*everyConfig)
+
+type everyConfig struct {
+	fixedDelay bool
+	skipIfBusy bool
+	timeout    time.Duration
+	ctx        context.Context
+}
+
+// WithFixedDelay starts every run a period after the previous run finishes.
+// By default runs start at fixed rate, a period apart.
+func WithFixedDelay() EveryOption {
+	return func(c *everyConfig) {
+		c.fixedDelay = true
+	}
+}
+
+// WithSkipIfBusy skips runs which are due while the previous run has not
+// finished. By default such run starts as soon as the previous one finishes.
+func WithSkipIfBusy() EveryOption {
+	return func(c *everyConfig) {
+		c.skipIfBusy = true
+	}
+}
+
+// WithRunTimeout cancels context of a run after d, see WithTimeout.
+func WithRunTimeout(d time.Duration) EveryOption {
+	return func(c *everyConfig) {
+		c.timeout = d
+	}
+}
+
+// WithRunContext sets parent context of runs.
+func WithRunContext(ctx context.Context) EveryOption {
+	return func(c *everyConfig) {
+		c.ctx = ctx
+	}
+}
+
+// RunStats describes runs of periodic job.
+type RunStats struct {
+	Runs uint64
+	// Skipped is number of runs skipped because job was busy.
+	Skipped uint64
+	// Missed is number of runs missed during suspend, they are folded into
+	// single run on wake up.
+	Missed       uint64
+	LastStart    Time
+	LastDuration time.Duration
+	LastError    error
+}
+
+// Periodic is a job started with Every. Runs never overlap.
+type Periodic struct {
+	mu     sync.Mutex
+	period time.Duration
+	job    func(ctx context.Context) error
+	cfg    everyConfig
+	timer  *Timer
+	armed  bool
+	next   Time
+	// pending is set when run is due while job is busy.
+	pending bool
+	running bool
+	paused  bool
+	stopped bool
+	cancel  context.CancelFunc
+	runs    sync.WaitGroup
+	stats   RunStats
+}
+
+// Every runs job every period starting one period from now. Each run gets
+// its own goroutine and context which is cancelled when run returns, times
+// out or Stop is called.
+func Every(period time.Duration, job func(ctx context.Context) error, opts ...EveryOption) *Periodic {
+	if period <= 0 {
+		panic(errors.New("non-positive interval for Every"))
+	}
+	p := &Periodic{
+		period: period,
+		job:    job,
+		cfg:    everyConfig{ctx: context.Background()},
+	}
+	for _, opt := range opts {
+		opt(&p.cfg)
+	}
+	p.mu.Lock()
+	p.arm(Now().Add(period))
+	p.mu.Unlock()
+	return p
+}
+
+// TriggerNow runs job now, following busy policy if it is running. Fixed
+// rate schedule is not changed, fixed delay is counted from the end of this
+// run.
+func (p *Periodic) TriggerNow() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped {
+		return
+	}
+	if p.cfg.fixedDelay && !p.running {
+		p.disarm()
+	}
+	p.due()
+}
+
+// Pause stops scheduling runs, current run is not interrupted.
+func (p *Periodic) Pause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.paused = true
+	p.pending = false
+	p.disarm()
+}
+
+// Resume continues scheduling runs after Pause. Fixed rate runs continue on
+// their original schedule, runs missed meanwhile are not made up.
+func (p *Periodic) Resume() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.paused || p.stopped {
+		return
+	}
+	p.paused = false
+	now := Now()
+	if !p.cfg.fixedDelay {
+		next, _ := p.nextTick(now)
+		p.arm(next)
+	} else if !p.running {
+		p.arm(now.Add(p.period))
+	}
+}
+
+// Stop stops scheduling runs, cancels context of current run and waits for
+// it to return.
+func (p *Periodic) Stop() {
+	p.mu.Lock()
+	p.stopped = true
+	p.pending = false
+	p.disarm()
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.mu.Unlock()
+	p.runs.Wait()
+}
+
+// Stats returns run statistics.
+func (p *Periodic) Stats() RunStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stats
+}
+
+// arm must be called with mu held.
+func (p *Periodic) arm(next Time) {
+	p.armed = true
+	p.next = next
+	p.timer = rearmFunc(p.timer, next.Sub(Now()), p.fire)
+}
+
+// disarm must be called with mu held.
+func (p *Periodic) disarm() {
+	p.armed = false
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+}
+
+// nextTick returns first fixed rate tick after now and number of ticks
+// missed since the scheduled one.
+func (p *Periodic) nextTick(now Time) (Time, uint64) {
+	next := p.next.Add(p.period)
+	if next.After(now) {
+		return next, 0
+	}
+	missed := now.Sub(p.next) / p.period
+	return p.next.Add((missed + 1) * p.period), uint64(missed)
+}
+
+func (p *Periodic) fire() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := Now()
+	if !p.armed || p.paused || p.stopped || now.Before(p.next) {
+		// Stopped, paused or re-armed after timer expired.
+		return
+	}
+	p.armed = false
+	if !p.cfg.fixedDelay {
+		next, missed := p.nextTick(now)
+		p.stats.Missed += missed
+		p.arm(next)
+	}
+	p.due()
+}
+
+// due must be called with mu held.
+func (p *Periodic) due() {
+	if !p.running {
+		p.start()
+		return
+	}
+	if p.cfg.skipIfBusy {
+		p.stats.Skipped++
+		return
+	}
+	p.pending = true
+}
+
+// start must be called with mu held.
+func (p *Periodic) start() {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+	if p.cfg.timeout > 0 {
+		ctx, cancel = WithTimeout(p.cfg.ctx, p.cfg.timeout)
+	} else {
+		ctx, cancel = context.WithCancel(p.cfg.ctx)
+	}
+	p.running = true
+	p.cancel = cancel
+	p.stats.LastStart = Now()
+	p.runs.Add(1)
+	go p.run(ctx, cancel)
+}
+
+func (p *Periodic) run(ctx context.Context, cancel context.CancelFunc) {
+	defer p.runs.Done()
+	start := Now()
+	err := p.job(ctx)
+	cancel()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stats.Runs++
+	p.stats.LastDuration = Since(start)
+	p.stats.LastError = err
+	p.running = false
+	p.cancel = nil
+	switch {
+	case p.stopped:
+	case p.pending:
+		p.pending = false
+		p.start()
+	case p.cfg.fixedDelay && !p.paused && !p.armed:
+		p.arm(Now().Add(p.period))
+	}
+}
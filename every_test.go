@@ -0,0 +1,218 @@
+package realtime
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// useInlineFakePoller is useFakePoller with inline executor, so ticks are
+// handled before advance returns.
+func useInlineFakePoller() (*fakePoller, func()) {
+	p, restore := useFakePoller()
+	Configure(WithExecutor(InlineExecutor()))
+	return p, func() {
+		Configure(WithExecutor(nil))
+		restore()
+	}
+}
+
+// waitRuns waits until periodic job finished n runs.
+func waitRuns(t *testing.T, p *Periodic, n uint64) RunStats {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		s := p.Stats()
+		if s.Runs == n {
+			return s
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d runs, got %d", n, s.Runs)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// blockingJob returns job which signals start of run and returns once
+// released.
+func blockingJob() (job func(context.Context) error, started chan struct{}, release chan struct{}) {
+	started = make(chan struct{}, 10)
+	release = make(chan struct{})
+	job = func(ctx context.Context) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	}
+	return job, started, release
+}
+
+func expectStarted(t *testing.T, started chan struct{}) {
+	t.Helper()
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("expected run to start")
+	}
+}
+
+func TestEveryFixedRate(t *testing.T) {
+	p, restore := useInlineFakePoller()
+	defer restore()
+
+	errJob := errors.New("job failed")
+	job := Every(time.Second, func(ctx context.Context) error {
+		return errJob
+	})
+	defer job.Stop()
+
+	for i := uint64(1); i <= 3; i++ {
+		p.advance(time.Second)
+		waitRuns(t, job, i)
+	}
+	s := job.Stats()
+	if s.LastError != errJob {
+		t.Fatalf("expected last error %v, got %v", errJob, s.LastError)
+	}
+	if s.LastStart != Now() {
+		t.Fatalf("expected last start at %s, got %s", Now(), s.LastStart)
+	}
+
+	// Runs missed during suspend are folded into one.
+	p.suspend(10 * time.Second)
+	s = waitRuns(t, job, 4)
+	if s.Missed != 9 {
+		t.Fatalf("expected 9 missed runs, got %d", s.Missed)
+	}
+}
+
+func TestEveryNoOverlap(t *testing.T) {
+	p, restore := useInlineFakePoller()
+	defer restore()
+
+	fn, started, release := blockingJob()
+	job := Every(time.Second, fn)
+	defer job.Stop()
+
+	p.advance(time.Second)
+	expectStarted(t, started)
+	p.advance(time.Second)
+	p.advance(time.Second)
+	release <- struct{}{}
+	// The second run starts when the first one finishes, the third one is
+	// merged with it.
+	expectStarted(t, started)
+	close(release)
+	s := waitRuns(t, job, 2)
+	if s.Skipped != 0 {
+		t.Fatalf("expected no skipped runs, got %d", s.Skipped)
+	}
+}
+
+func TestEverySkipIfBusy(t *testing.T) {
+	p, restore := useInlineFakePoller()
+	defer restore()
+
+	fn, started, release := blockingJob()
+	job := Every(time.Second, fn, WithSkipIfBusy())
+	defer job.Stop()
+
+	p.advance(time.Second)
+	expectStarted(t, started)
+	p.advance(time.Second)
+	p.advance(time.Second)
+	close(release)
+	s := waitRuns(t, job, 1)
+	if s.Skipped != 2 {
+		t.Fatalf("expected 2 skipped runs, got %d", s.Skipped)
+	}
+}
+
+func TestEveryFixedDelay(t *testing.T) {
+	p, restore := useInlineFakePoller()
+	defer restore()
+
+	fn, started, release := blockingJob()
+	job := Every(time.Second, fn, WithFixedDelay())
+	defer job.Stop()
+
+	p.advance(time.Second)
+	expectStarted(t, started)
+	p.advance(5 * time.Second)
+	release <- struct{}{}
+	waitRuns(t, job, 1)
+
+	// Next run is a period after the end of the previous one.
+	p.advance(999 * time.Millisecond)
+	waitRuns(t, job, 1)
+	p.advance(time.Millisecond)
+	expectStarted(t, started)
+	close(release)
+	waitRuns(t, job, 2)
+}
+
+func TestEveryPauseResumeTrigger(t *testing.T) {
+	p, restore := useInlineFakePoller()
+	defer restore()
+
+	job := Every(time.Second, func(ctx context.Context) error {
+		return nil
+	})
+	defer job.Stop()
+
+	job.Pause()
+	p.advance(3 * time.Second)
+	waitRuns(t, job, 0)
+
+	job.TriggerNow()
+	waitRuns(t, job, 1)
+
+	job.Resume()
+	p.advance(time.Second)
+	waitRuns(t, job, 2)
+
+	job.Stop()
+	p.advance(time.Second)
+	job.TriggerNow()
+	waitRuns(t, job, 2)
+}
+
+func TestEveryRunTimeout(t *testing.T) {
+	p, restore := useInlineFakePoller()
+	defer restore()
+
+	started := make(chan struct{})
+	job := Every(time.Second, func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}, WithRunTimeout(500*time.Millisecond))
+	defer job.Stop()
+
+	p.advance(time.Second)
+	<-started
+	p.advance(500 * time.Millisecond)
+	s := waitRuns(t, job, 1)
+	if s.LastError != context.DeadlineExceeded {
+		t.Fatalf("expected %v, got %v", context.DeadlineExceeded, s.LastError)
+	}
+}
+
+func TestEveryStopCancelsRun(t *testing.T) {
+	p, restore := useInlineFakePoller()
+	defer restore()
+
+	started := make(chan struct{})
+	job := Every(time.Second, func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	p.advance(time.Second)
+	<-started
+	job.Stop()
+	if err := job.Stats().LastError; err != context.Canceled {
+		t.Fatalf("expected %v, got %v", context.Canceled, err)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	_ "net/http/pprof"
 
 	"github.com/anjmao/realtime"
+	_ "github.com/anjmao/realtime/debug"
 )
 
 func randSleep() {
@@ -0,0 +1,169 @@
+package realtime
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+)
+
+// Executor runs AfterFunc callbacks. Execute is called from the poller
+// goroutine so it must not block for long.
+type Executor interface {
+	Execute(f func())
+}
+
+// ExecutorFunc adapts function to Executor.
+type ExecutorFunc func(f func())
+
+func (e ExecutorFunc) Execute(f func()) {
+	e(f)
+}
+
+// SpawnExecutor runs every callback in a new goroutine. It is the default.
+func SpawnExecutor() Executor {
+	return ExecutorFunc(func(f func()) {
+		go f()
+	})
+}
+
+// InlineExecutor runs callbacks directly on the poller goroutine. It is only
+// suitable for trusted callbacks which return quickly, slow callback delays
+// all other timers.
+func InlineExecutor() Executor {
+	return ExecutorFunc(func(f func()) {
+		f()
+	})
+}
+
+// PoolExecutor runs callbacks on a fixed number of worker goroutines.
+type PoolExecutor struct {
+	tasks     chan func()
+	overflow  OverflowPolicy
+	closeOnce sync.Once
+}
+
+// OverflowPolicy decides what PoolExecutor does with callback when all
+// workers are busy and queue is full.
+type OverflowPolicy int
+
+const (
+	// DropOverflow drops the callback, so poller is never blocked. Dropped
+	// callbacks are logged and counted in Stats.
+	DropOverflow OverflowPolicy = iota
+	// BlockOverflow blocks poller until a worker takes the callback, all
+	// other timers are delayed meanwhile.
+	BlockOverflow
+)
+
+// PoolOption configures PoolExecutor.
+type PoolOption func(*PoolExecutor)
+
+// WithOverflow sets overflow policy of pool. Default is DropOverflow.
+func WithOverflow(p OverflowPolicy) PoolOption {
+	return func(e *PoolExecutor) {
+		e.overflow = p
+	}
+}
+
+// NewPoolExecutor starts workers goroutines with a queue of given size. At
+// most workers callbacks run at once, callbacks which do not fit to the
+// queue are handled by overflow policy and counted as saturations in Stats.
+func NewPoolExecutor(workers, queue int, opts ...PoolOption) *PoolExecutor {
+	if workers < 1 {
+		workers = 1
+	}
+	p := &PoolExecutor{
+		tasks: make(chan func(), queue),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+func (p *PoolExecutor) Execute(f func()) {
+	atomic.AddInt64(&stats.executorQueued, 1)
+	select {
+	case p.tasks <- f:
+		return
+	default:
+	}
+	atomic.AddUint64(&stats.executorSaturations, 1)
+	if p.overflow == BlockOverflow {
+		p.tasks <- f
+		return
+	}
+	atomic.AddInt64(&stats.executorQueued, -1)
+	atomic.AddUint64(&stats.executorDropped, 1)
+	if logEnabled(LevelWarn) {
+		logger().Log(LevelWarn, "pool executor queue is full, callback dropped")
+	}
+}
+
+// Close stops workers after queued callbacks are run. Execute must not be
+// called after Close.
+func (p *PoolExecutor) Close() {
+	p.closeOnce.Do(func() {
+		close(p.tasks)
+	})
+}
+
+func (p *PoolExecutor) work() {
+	for f := range p.tasks {
+		atomic.AddInt64(&stats.executorQueued, -1)
+		f()
+	}
+}
+
+// HandlerPanic describes panic recovered from timer callback.
+type HandlerPanic struct {
+	TimerID uint64
+	Kind    string
+	Value   interface{}
+	Stack   string
+}
+
+func (p HandlerPanic) String() string {
+	return fmt.Sprintf("panic in %s#%d: %v\n%s", p.Kind, p.TimerID, p.Value, p.Stack)
+}
+
+// WithExecutor sets executor for AfterFunc callbacks.
+func WithExecutor(e Executor) Option {
+	return func(c *config) {
+		if e == nil {
+			e = SpawnExecutor()
+		}
+		c.executor = e
+	}
+}
+
+// WithPanicHook sets function which is called with every panic recovered
+// from timer callbacks. Panics are always logged.
+func WithPanicHook(f func(HandlerPanic)) Option {
+	return func(c *config) {
+		c.panicHook = f
+	}
+}
+
+// recoverHandler must be deferred directly by function running callback.
+func recoverHandler(id uint64, kind eventKind) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	p := HandlerPanic{
+		TimerID: id,
+		Kind:    kind.String(),
+		Value:   r,
+		Stack:   string(debug.Stack()),
+	}
+	atomic.AddUint64(&stats.handlerPanics, 1)
+	logger().Log(LevelError, "handler panic", "id", id, "kind", p.Kind, "panic", r, "stack", p.Stack)
+	if hook := getConfig().panicHook; hook != nil {
+		hook(p)
+	}
+}
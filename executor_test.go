@@ -0,0 +1,159 @@
+package realtime
+
+import (
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAfterFuncPanicHook(t *testing.T) {
+	panics := make(chan HandlerPanic, 1)
+	Configure(WithPanicHook(func(p HandlerPanic) {
+		panics <- p
+	}))
+	defer Configure(WithPanicHook(nil))
+
+	timer := AfterFunc(time.Millisecond, func() {
+		panic("boom")
+	})
+
+	p := <-panics
+	if p.TimerID != timer.r.id || p.Kind != "timer" || p.Value != "boom" {
+		t.Fatalf("unexpected panic %v", p)
+	}
+
+	// Poller must survive callback panic.
+	<-After(time.Millisecond)
+}
+
+func TestInlineExecutorPanic(t *testing.T) {
+	panics := make(chan HandlerPanic, 1)
+	Configure(WithExecutor(InlineExecutor()), WithPanicHook(func(p HandlerPanic) {
+		panics <- p
+	}))
+	defer Configure(WithExecutor(nil), WithPanicHook(nil))
+
+	AfterFunc(0, func() {
+		panic("inline")
+	})
+	if p := <-panics; p.Value != "inline" {
+		t.Fatalf("unexpected panic %v", p)
+	}
+	<-After(time.Millisecond)
+}
+
+func TestPoolExecutor(t *testing.T) {
+	pool := NewPoolExecutor(1, 1)
+	defer pool.Close()
+	Configure(WithExecutor(pool))
+	defer Configure(WithExecutor(nil))
+
+	const n = 5
+	release := make(chan struct{})
+	started := make(chan struct{}, n)
+	for i := 0; i < n; i++ {
+		AfterFunc(0, func() {
+			started <- struct{}{}
+			<-release
+		})
+	}
+	// Saturated pool does not block poller.
+	select {
+	case <-After(10 * time.Millisecond):
+	case <-time.After(time.Second):
+		t.Fatal("poller is blocked by saturated pool")
+	}
+	close(release)
+	<-started
+}
+
+// saturate starts workers blocking callbacks and fills queue of pool.
+func saturate(t *testing.T, pool *PoolExecutor, workers, queue int, release chan struct{}, running *int32, maxRunning *int32) {
+	t.Helper()
+	started := make(chan struct{}, workers)
+	f := func() {
+		n := atomic.AddInt32(running, 1)
+		for {
+			max := atomic.LoadInt32(maxRunning)
+			if n <= max || atomic.CompareAndSwapInt32(maxRunning, max, n) {
+				break
+			}
+		}
+		started <- struct{}{}
+		<-release
+		atomic.AddInt32(running, -1)
+	}
+	for i := 0; i < workers; i++ {
+		pool.Execute(f)
+		<-started
+	}
+	for i := 0; i < queue; i++ {
+		pool.Execute(f)
+	}
+}
+
+func TestPoolExecutorDropOverflow(t *testing.T) {
+	const workers, queue = 2, 1
+	pool := NewPoolExecutor(workers, queue)
+	defer pool.Close()
+	goroutines := runtime.NumGoroutine()
+	before := Stats()
+
+	release := make(chan struct{})
+	var running, maxRunning int32
+	saturate(t, pool, workers, queue, release, &running, &maxRunning)
+	ran := int32(0)
+	for i := 0; i < 10; i++ {
+		pool.Execute(func() {
+			atomic.AddInt32(&ran, 1)
+		})
+	}
+	if n := runtime.NumGoroutine(); n > goroutines {
+		t.Fatalf("expected no goroutines over %d, got %d", goroutines, n)
+	}
+	after := Stats()
+	if dropped := after.ExecutorDropped - before.ExecutorDropped; dropped != 10 {
+		t.Fatalf("expected 10 dropped callbacks, got %d", dropped)
+	}
+	if saturations := after.ExecutorSaturations - before.ExecutorSaturations; saturations != 10 {
+		t.Fatalf("expected 10 saturations, got %d", saturations)
+	}
+
+	close(release)
+	waitFor(t, func() bool { return atomic.LoadInt32(&running) == 0 })
+	if max := atomic.LoadInt32(&maxRunning); max > workers {
+		t.Fatalf("expected at most %d callbacks running, got %d", workers, max)
+	}
+	if n := atomic.LoadInt32(&ran); n != 0 {
+		t.Fatalf("expected dropped callbacks not to run, %d ran", n)
+	}
+}
+
+func TestPoolExecutorBlockOverflow(t *testing.T) {
+	const workers, queue = 1, 1
+	pool := NewPoolExecutor(workers, queue, WithOverflow(BlockOverflow))
+	defer pool.Close()
+
+	release := make(chan struct{})
+	var running, maxRunning int32
+	saturate(t, pool, workers, queue, release, &running, &maxRunning)
+
+	ran := make(chan struct{})
+	executed := make(chan struct{})
+	go func() {
+		pool.Execute(func() { close(ran) })
+		close(executed)
+	}()
+	select {
+	case <-executed:
+		t.Fatal("expected Execute to block while pool is saturated")
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(release)
+	<-executed
+	<-ran
+	if max := atomic.LoadInt32(&maxRunning); max > workers {
+		t.Fatalf("expected at most %d callbacks running, got %d", workers, max)
+	}
+}
@@ -0,0 +1,173 @@
+package realtime
+
+import (
+	"sync"
+	"time"
+)
+
+// fakePoller is poller with manually advanced clock. It replaces both engine
+// and clock, so expirations happen only when test advances time.
+type fakePoller struct {
+	mu  sync.Mutex
+	now int64
+	// suspended is time spent in suspend, it does not count as awake time.
+	suspended int64
+	events    map[uint64]*fakeEvent
+	armed     []time.Duration
+}
+
+type fakeEvent struct {
+	kind     eventKind
+	deadline int64
+	period   time.Duration
+	handler  timerHandler
+}
+
+// useFakePoller replaces engine and clock with fake poller until returned
+// function is called.
+func useFakePoller() (*fakePoller, func()) {
+	p := &fakePoller{
+		now:    int64(nanotime()),
+		events: map[uint64]*fakeEvent{},
+	}
+	prevEngine, prevClock, prevAwake := getEngine(), clockNow, awakeNow
+	setEngine(p)
+	clockNow = p.nanotime
+	awakeNow = p.awake
+	return p, func() {
+		setEngine(prevEngine)
+		clockNow = prevClock
+		awakeNow = prevAwake
+	}
+}
+
+func (p *fakePoller) awake() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return time.Duration(p.now - p.suspended)
+}
+
+func (p *fakePoller) nanotime() uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return uint64(p.now)
+}
+
+func (p *fakePoller) registerEvent(id uint64, kind eventKind, d, period time.Duration, handler timerHandler) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if d <= 0 {
+		d = 1
+	}
+	p.events[id] = &fakeEvent{kind: kind, deadline: p.now + int64(d), period: period, handler: handler}
+	p.armed = append(p.armed, d)
+	return nil
+}
+
+func (p *fakePoller) deleteEvent(id uint64) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.events, id)
+	return nil
+}
+
+func (p *fakePoller) remaining(id uint64) (time.Duration, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if e, ok := p.events[id]; ok {
+		return time.Duration(e.deadline - p.now), nil
+	}
+	return 0, nil
+}
+
+func (p *fakePoller) close() {}
+
+func (p *fakePoller) timers() []TimerInfo {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var infos []TimerInfo
+	for id, e := range p.events {
+		infos = append(infos, TimerInfo{
+			ID:        id,
+			Kind:      e.kind.String(),
+			Period:    e.period,
+			Deadline:  Time{ns: time.Duration(e.deadline)},
+			Remaining: time.Duration(e.deadline - p.now),
+		})
+	}
+	return infos
+}
+
+// advance moves clock forward by d firing events which expire meanwhile in
+// deadline order. Every event is fired at its deadline.
+func (p *fakePoller) advance(d time.Duration) {
+	p.mu.Lock()
+	end := p.now + int64(d)
+	p.mu.Unlock()
+
+	for p.fireNext(end) {
+	}
+
+	p.mu.Lock()
+	p.now = end
+	p.mu.Unlock()
+}
+
+// advanceToNext moves clock to deadline of earliest event and fires it.
+func (p *fakePoller) advanceToNext() {
+	p.fireNext(1<<63 - 1)
+}
+
+// suspend moves clock forward by d without firing events, as if machine was
+// suspended, and then fires expired events once with number of expirations
+// missed meanwhile.
+func (p *fakePoller) suspend(d time.Duration) {
+	p.mu.Lock()
+	p.now += int64(d)
+	p.suspended += int64(d)
+	end := p.now
+	p.mu.Unlock()
+
+	for p.fireNext(end) {
+	}
+}
+
+func (p *fakePoller) fireNext(end int64) bool {
+	p.mu.Lock()
+	var (
+		id uint64
+		e  *fakeEvent
+	)
+	for eid, ev := range p.events {
+		if ev.deadline <= end && (e == nil || ev.deadline < e.deadline) {
+			id, e = eid, ev
+		}
+	}
+	if e == nil {
+		p.mu.Unlock()
+		return false
+	}
+	if e.deadline > p.now {
+		p.now = e.deadline
+	}
+	var expirations uint64 = 1
+	if e.kind == timerEvent {
+		delete(p.events, id)
+	} else {
+		expirations += uint64((p.now - e.deadline) / int64(e.period))
+		e.deadline += int64(expirations) * int64(e.period)
+	}
+	p.mu.Unlock()
+
+	callHandler(&event{id: id, kind: e.kind, expirations: expirations, handler: e.handler})
+	return true
+}
+
+// armedDurations returns durations of all arms since last call.
+func (p *fakePoller) armedDurations() []time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	armed := p.armed
+	p.armed = nil
+	return armed
+}
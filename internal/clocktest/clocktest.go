@@ -0,0 +1,110 @@
+// Package clocktest provides fake realtime.Clock for tests.
+package clocktest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/anjmao/realtime"
+)
+
+// Clock is a fake clock which moves only when advanced. Zero value is not
+// usable, use New.
+type Clock struct {
+	mu     sync.Mutex
+	now    realtime.Time
+	timers map[*Timer]struct{}
+}
+
+// New returns fake clock starting at current realtime.
+func New() *Clock {
+	return &Clock{
+		now:    realtime.Now(),
+		timers: map[*Timer]struct{}{},
+	}
+}
+
+// Now returns fake current time.
+func (c *Clock) Now() realtime.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTimer returns timer which expires when clock is advanced by d.
+func (c *Clock) NewTimer(d time.Duration) realtime.ClockTimer {
+	t := &Timer{c: c, ch: make(chan realtime.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+// Advance moves clock forward by d and fires expired timers.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	for t := range c.timers {
+		if !t.deadline.After(c.now) {
+			delete(c.timers, t)
+			select {
+			case t.ch <- c.now:
+			default:
+			}
+		}
+	}
+	c.mu.Unlock()
+}
+
+// Timers returns number of active timers.
+func (c *Clock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// WaitTimers waits until there are at least n active timers, e.g. until
+// goroutine under test starts waiting.
+func (c *Clock) WaitTimers(n int) {
+	for c.Timers() < n {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Timer is a timer of fake Clock.
+type Timer struct {
+	c        *Clock
+	ch       chan realtime.Time
+	deadline realtime.Time
+}
+
+// Chan returns channel which receives time when timer expires.
+func (t *Timer) Chan() <-chan realtime.Time {
+	return t.ch
+}
+
+// Stop stops timer and reports whether it was active.
+func (t *Timer) Stop() bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	_, active := t.c.timers[t]
+	delete(t.c.timers, t)
+	return active
+}
+
+// Reset re-arms timer to expire after d and reports whether it was active.
+func (t *Timer) Reset(d time.Duration) bool {
+	t.c.mu.Lock()
+	_, active := t.c.timers[t]
+	t.deadline = t.c.now.Add(d)
+	if d <= 0 {
+		delete(t.c.timers, t)
+		t.c.mu.Unlock()
+		select {
+		case t.ch <- t.deadline:
+		default:
+		}
+		return active
+	}
+	t.c.timers[t] = struct{}{}
+	t.c.mu.Unlock()
+	return active
+}
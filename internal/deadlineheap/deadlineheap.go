@@ -0,0 +1,127 @@
+// Package deadlineheap provides heap which keeps track of positions of its
+// elements, so they can be removed or re-ordered when their deadline moves.
+package deadlineheap
+
+import "container/heap"
+
+// Item is embedded in heap elements.
+type Item struct {
+	index int // -1 if element is not in heap
+	seq   uint64
+}
+
+func (it *Item) item() *Item {
+	return it
+}
+
+// Elem is element of Heap, it is a pointer to struct which embeds Item.
+type Elem interface {
+	item() *Item
+}
+
+// Heap orders elements by less, elements which are equal are ordered by
+// order they were pushed. Zero value is not usable, use New.
+type Heap struct {
+	h elems
+}
+
+// New returns empty heap ordered by less.
+func New(less func(a, b Elem) bool) *Heap {
+	return &Heap{h: elems{less: less}}
+}
+
+// Len returns number of elements.
+func (h *Heap) Len() int {
+	return len(h.h.elems)
+}
+
+// Peek returns the least element or nil if heap is empty.
+func (h *Heap) Peek() Elem {
+	if len(h.h.elems) == 0 {
+		return nil
+	}
+	return h.h.elems[0]
+}
+
+// Push adds element.
+func (h *Heap) Push(e Elem) {
+	h.h.seq++
+	e.item().seq = h.h.seq
+	heap.Push(&h.h, e)
+}
+
+// Pop removes and returns the least element or nil if heap is empty.
+func (h *Heap) Pop() Elem {
+	if len(h.h.elems) == 0 {
+		return nil
+	}
+	return heap.Pop(&h.h).(Elem)
+}
+
+// Contains reports whether element is in heap.
+func (h *Heap) Contains(e Elem) bool {
+	i := e.item().index
+	return i >= 0 && i < len(h.h.elems) && h.h.elems[i] == e
+}
+
+// Remove removes element and reports whether it was in heap.
+func (h *Heap) Remove(e Elem) bool {
+	if !h.Contains(e) {
+		return false
+	}
+	heap.Remove(&h.h, e.item().index)
+	return true
+}
+
+// Fix re-orders element after its deadline changed, element which is not in
+// heap is pushed.
+func (h *Heap) Fix(e Elem) {
+	if !h.Contains(e) {
+		h.Push(e)
+		return
+	}
+	heap.Fix(&h.h, e.item().index)
+}
+
+// elems implements heap.Interface.
+type elems struct {
+	elems []Elem
+	less  func(a, b Elem) bool
+	seq   uint64
+}
+
+func (h elems) Len() int {
+	return len(h.elems)
+}
+
+func (h elems) Less(i, j int) bool {
+	a, b := h.elems[i], h.elems[j]
+	if h.less(a, b) {
+		return true
+	}
+	if h.less(b, a) {
+		return false
+	}
+	return a.item().seq < b.item().seq
+}
+
+func (h elems) Swap(i, j int) {
+	h.elems[i], h.elems[j] = h.elems[j], h.elems[i]
+	h.elems[i].item().index = i
+	h.elems[j].item().index = j
+}
+
+func (h *elems) Push(x interface{}) {
+	e := x.(Elem)
+	e.item().index = len(h.elems)
+	h.elems = append(h.elems, e)
+}
+
+func (h *elems) Pop() interface{} {
+	old := h.elems
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	h.elems = old[:len(old)-1]
+	e.item().index = -1
+	return e
+}
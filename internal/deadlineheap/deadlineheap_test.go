@@ -0,0 +1,48 @@
+package deadlineheap
+
+import "testing"
+
+type elem struct {
+	Item
+	deadline int
+	name     string
+}
+
+func newHeap() *Heap {
+	return New(func(a, b Elem) bool {
+		return a.(*elem).deadline < b.(*elem).deadline
+	})
+}
+
+func popAll(h *Heap) string {
+	var names string
+	for h.Len() > 0 {
+		names += h.Pop().(*elem).name
+	}
+	return names
+}
+
+func TestHeap(t *testing.T) {
+	h := newHeap()
+	if h.Peek() != nil || h.Pop() != nil {
+		t.Fatal("expected empty heap")
+	}
+	a, b, c, d := &elem{deadline: 3, name: "a"}, &elem{deadline: 1, name: "b"}, &elem{deadline: 2, name: "c"}, &elem{deadline: 1, name: "d"}
+	for _, e := range []*elem{a, b, c, d} {
+		h.Push(e)
+	}
+	if h.Peek() != Elem(b) {
+		t.Fatal("expected the earliest element at head")
+	}
+	if !h.Remove(c) || h.Remove(c) || h.Contains(c) {
+		t.Fatal("expected element to be removed once")
+	}
+	a.deadline = 0
+	h.Fix(a)
+	if names := popAll(h); names != "abd" {
+		t.Fatalf("expected abd, got %s", names)
+	}
+	if h.Contains(a) || h.Remove(a) {
+		t.Fatal("expected popped element not to be in heap")
+	}
+}
@@ -0,0 +1,92 @@
+package wallclock
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// pollInterval bounds how late poll timer notices deadline or clock set.
+	pollInterval = time.Second
+	// stepThreshold is difference between wall and monotonic clock progress
+	// which is considered clock set. Slewing is much slower.
+	stepThreshold = 50 * time.Millisecond
+)
+
+// pollTimer sleeps on monotonic clock for at most pollInterval and re-reads
+// wall clock on every wake up.
+type pollTimer struct {
+	mu       sync.Mutex
+	deadline time.Time
+	fired    bool
+	wake     chan struct{}
+	done     chan struct{}
+	once     sync.Once
+}
+
+func newPollTimer(deadline time.Time, signal func()) *pollTimer {
+	t := &pollTimer{
+		deadline: deadline.Round(0),
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	go t.poll(signal)
+	return t
+}
+
+func (t *pollTimer) reset(deadline time.Time) {
+	t.mu.Lock()
+	t.deadline = deadline.Round(0)
+	t.fired = false
+	t.mu.Unlock()
+	select {
+	case t.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (t *pollTimer) stop() {
+	t.once.Do(func() {
+		close(t.done)
+	})
+}
+
+func (t *pollTimer) poll(signal func()) {
+	timer := time.NewTimer(pollInterval)
+	defer timer.Stop()
+	last := time.Now()
+	for {
+		// Wall readings have monotonic part stripped, so deadline is
+		// compared with wall clock.
+		now := time.Now()
+		if step := now.Round(0).Sub(last.Round(0)) - now.Sub(last); step > stepThreshold || step < -stepThreshold {
+			signal()
+		}
+		last = now
+
+		t.mu.Lock()
+		d := t.deadline.Sub(now.Round(0))
+		if d <= 0 && !t.fired {
+			t.fired = true
+			signal()
+		}
+		t.mu.Unlock()
+		if d <= 0 || d > pollInterval {
+			d = pollInterval
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(d)
+		select {
+		case <-timer.C:
+		case <-t.wake:
+		case <-t.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,130 @@
+// Package wallclock provides timers which follow wall clock time and notice
+// when wall clock is set.
+package wallclock
+
+import (
+	"sync"
+	"time"
+)
+
+// Timer fires when wall clock reaches its deadline. It also fires when wall
+// clock is set, so owner re-reads time and resets the timer if deadline is
+// not reached yet.
+//
+// On Linux timer is CLOCK_REALTIME timerfd armed with TFD_TIMER_ABSTIME and
+// TFD_TIMER_CANCEL_ON_SET. Elsewhere, or when timerfd is not available, wall
+// clock is polled every pollInterval and compared with monotonic clock.
+type Timer struct {
+	C <-chan struct{}
+	c chan struct{}
+	t sysTimer
+}
+
+type sysTimer interface {
+	reset(deadline time.Time)
+	stop()
+}
+
+// NewTimer returns timer which fires at deadline.
+func NewTimer(deadline time.Time) *Timer {
+	c := make(chan struct{}, 1)
+	t := &Timer{C: c, c: c}
+	st, err := newSysTimer(deadline, t.signal)
+	if err != nil {
+		st = newPollTimer(deadline, t.signal)
+	}
+	t.t = st
+	return t
+}
+
+// Reset moves deadline, notifications sent before are dropped. Reset must
+// not be called after Stop.
+func (t *Timer) Reset(deadline time.Time) {
+	select {
+	case <-t.c:
+	default:
+	}
+	t.t.reset(deadline)
+}
+
+// Stop stops timer and releases its resources.
+func (t *Timer) Stop() {
+	t.t.stop()
+}
+
+func (t *Timer) signal() {
+	select {
+	case t.c <- struct{}{}:
+	default:
+	}
+}
+
+// never is deadline which is never reached, timer armed with it only notices
+// clock sets.
+var never = time.Unix(1<<33, 0)
+
+var watchers struct {
+	mu     sync.Mutex
+	lastID int
+	funcs  map[int]func()
+	timer  *Timer
+	done   chan struct{}
+}
+
+// OnSet calls f whenever wall clock is set until returned function is
+// called. Functions are called one by one from a single goroutine, f may be
+// called once more while cancel runs.
+func OnSet(f func()) (cancel func()) {
+	w := &watchers
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.funcs == nil {
+		w.funcs = map[int]func(){}
+	}
+	if w.timer == nil {
+		w.timer = NewTimer(never)
+		w.done = make(chan struct{})
+		go watch(w.timer, w.done)
+	}
+	w.lastID++
+	id := w.lastID
+	w.funcs[id] = f
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			w.mu.Lock()
+			defer w.mu.Unlock()
+			delete(w.funcs, id)
+			if len(w.funcs) == 0 {
+				w.timer.Stop()
+				close(w.done)
+				w.timer, w.done = nil, nil
+			}
+		})
+	}
+}
+
+func watch(t *Timer, done chan struct{}) {
+	for {
+		select {
+		case <-t.C:
+		case <-done:
+			return
+		}
+		w := &watchers
+		w.mu.Lock()
+		if w.timer != t {
+			w.mu.Unlock()
+			return
+		}
+		funcs := make([]func(), 0, len(w.funcs))
+		for _, f := range w.funcs {
+			funcs = append(funcs, f)
+		}
+		w.mu.Unlock()
+		for _, f := range funcs {
+			f()
+		}
+	}
+}
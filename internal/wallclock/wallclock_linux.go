@@ -0,0 +1,91 @@
+package wallclock
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	tfdTimerAbstime     = 1 << 0
+	tfdTimerCancelOnSet = 1 << 1
+)
+
+// timerFd is CLOCK_REALTIME timerfd. Its read fails with ECANCELED when wall
+// clock is set.
+type timerFd struct {
+	f      *os.File
+	signal func()
+}
+
+type timerSpec struct {
+	ItInterval unix.Timespec
+	ItValue    unix.Timespec
+}
+
+func newSysTimer(deadline time.Time, signal func()) (sysTimer, error) {
+	fd, _, errno := unix.Syscall(unix.SYS_TIMERFD_CREATE, unix.CLOCK_REALTIME, unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if errno != 0 {
+		return nil, errno
+	}
+	if err := setTime(int(fd), deadline); err != nil {
+		unix.Close(int(fd))
+		return nil, err
+	}
+	// Non-blocking descriptor is added to Go netpoller, so Close wakes up
+	// blocked Read.
+	t := &timerFd{f: os.NewFile(fd, "timerfd"), signal: signal}
+	go t.wait()
+	return t, nil
+}
+
+func (t *timerFd) reset(deadline time.Time) {
+	conn, err := t.f.SyscallConn()
+	if err != nil {
+		return
+	}
+	conn.Control(func(fd uintptr) {
+		err = setTime(int(fd), deadline)
+	})
+	if err != nil {
+		// Owner re-reads time and resets timer again.
+		t.signal()
+	}
+}
+
+func (t *timerFd) stop() {
+	t.f.Close()
+}
+
+func (t *timerFd) wait() {
+	var buf [8]byte
+	for {
+		_, err := t.f.Read(buf[:])
+		if err != nil && !errors.Is(err, syscall.ECANCELED) {
+			return
+		}
+		t.signal()
+	}
+}
+
+func setTime(fd int, deadline time.Time) error {
+	ns := deadline.UnixNano()
+	if deadline.After(never) {
+		ns = never.UnixNano()
+	}
+	if ns <= 0 {
+		// Zero value disarms timer.
+		ns = 1
+	}
+	spec := timerSpec{ItValue: unix.NsecToTimespec(ns)}
+	_, _, errno := unix.Syscall6(unix.SYS_TIMERFD_SETTIME, uintptr(fd), tfdTimerAbstime|tfdTimerCancelOnSet,
+		uintptr(unsafe.Pointer(&spec)), 0, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
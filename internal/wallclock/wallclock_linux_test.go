@@ -0,0 +1,55 @@
+package wallclock
+
+import (
+	"testing"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// stepClock sets wall clock to its current value, which is enough for kernel
+// to cancel timers. Test is skipped without CAP_SYS_TIME.
+func stepClock(t *testing.T) {
+	var ts unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_REALTIME, &ts); err != nil {
+		t.Fatal(err)
+	}
+	_, _, errno := unix.Syscall(unix.SYS_CLOCK_SETTIME, unix.CLOCK_REALTIME, uintptr(unsafe.Pointer(&ts)), 0)
+	if errno == unix.EPERM {
+		t.Skip("setting wall clock is not permitted")
+	}
+	if errno != 0 {
+		t.Fatal(errno)
+	}
+}
+
+func TestTimerClockSet(t *testing.T) {
+	timer := NewTimer(time.Now().Add(time.Hour))
+	defer timer.Stop()
+
+	stepClock(t)
+	select {
+	case <-timer.C:
+	case <-time.After(time.Second):
+		t.Fatal("timer did not fire when wall clock was set")
+	}
+}
+
+func TestOnSet(t *testing.T) {
+	called := make(chan struct{}, 1)
+	cancel := OnSet(func() {
+		select {
+		case called <- struct{}{}:
+		default:
+		}
+	})
+	defer cancel()
+
+	stepClock(t)
+	select {
+	case <-called:
+	case <-time.After(time.Second):
+		t.Fatal("function was not called when wall clock was set")
+	}
+}
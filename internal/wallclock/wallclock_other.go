@@ -0,0 +1,12 @@
+// +build !linux
+
+package wallclock
+
+import (
+	"errors"
+	"time"
+)
+
+func newSysTimer(deadline time.Time, signal func()) (sysTimer, error) {
+	return nil, errors.New("wall clock timer is not supported")
+}
@@ -0,0 +1,82 @@
+package wallclock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimer(t *testing.T) {
+	deadline := time.Now().Add(20 * time.Millisecond)
+	timer := NewTimer(deadline)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-time.After(time.Second):
+		t.Fatal("timer did not fire")
+	}
+	if now := time.Now(); now.Before(deadline) {
+		t.Fatalf("timer fired %s before deadline", deadline.Sub(now))
+	}
+}
+
+func TestTimerReset(t *testing.T) {
+	timer := NewTimer(time.Now().Add(time.Hour))
+	defer timer.Stop()
+
+	deadline := time.Now().Add(20 * time.Millisecond)
+	timer.Reset(deadline)
+	select {
+	case <-timer.C:
+	case <-time.After(time.Second):
+		t.Fatal("timer did not fire after reset")
+	}
+	if time.Now().Before(deadline) {
+		t.Fatal("timer fired before deadline")
+	}
+
+	timer.Reset(time.Now().Add(time.Hour))
+	select {
+	case <-timer.C:
+		t.Fatal("timer fired long before deadline")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestPollTimer(t *testing.T) {
+	c := make(chan struct{}, 1)
+	deadline := time.Now().Add(20 * time.Millisecond)
+	timer := newPollTimer(deadline, func() {
+		select {
+		case c <- struct{}{}:
+		default:
+		}
+	})
+	defer timer.stop()
+
+	select {
+	case <-c:
+	case <-time.After(time.Second):
+		t.Fatal("poll timer did not fire")
+	}
+	if time.Now().Before(deadline) {
+		t.Fatal("poll timer fired before deadline")
+	}
+	select {
+	case <-c:
+		t.Fatal("poll timer fired twice")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestOnSetCancel(t *testing.T) {
+	cancel := OnSet(func() {})
+	cancel()
+	cancel()
+
+	watchers.mu.Lock()
+	defer watchers.mu.Unlock()
+	if watchers.timer != nil || len(watchers.funcs) != 0 {
+		t.Fatal("watcher is not released after cancel")
+	}
+}
@@ -0,0 +1,85 @@
+package realtime
+
+import (
+	"errors"
+	"math/rand"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// TimerOption configures single timer.
+type TimerOption func(*timer)
+
+// WithJitter randomly moves timer expiration by up to fraction of its delay
+// in either direction. Fraction is clamped to [0, 1]. Timer Deadline still
+// reports nominal deadline.
+func WithJitter(fraction float64) TimerOption {
+	return func(r *timer) {
+		r.jitter = clampFraction(fraction)
+	}
+}
+
+// WithJitterSeed makes jitter deterministic by seeding its random source.
+func WithJitterSeed(seed int64) Option {
+	return func(c *config) {
+		c.jitter = newJitterSource(seed)
+	}
+}
+
+// NewJitteredTicker returns ticker which ticks every period moved randomly by
+// up to jitterFraction of period in either direction. Jitter does not
+// accumulate, every tick is scheduled relative to its nominal time, and
+// ticks missed during suspend are skipped.
+func NewJitteredTicker(period time.Duration, jitterFraction float64) *Ticker {
+	if period <= 0 {
+		panic(errors.New("non-positive interval for NewJitteredTicker"))
+	}
+
+	r := newTimerState(tickerEvent, newTimerChan(), nil, []TimerOption{WithJitter(jitterFraction)})
+	t := &Ticker{
+		C: r.c,
+		r: r,
+	}
+	nominal := Now().Add(period)
+	r.next = func() time.Duration {
+		now := Now()
+		nominal = nominal.Add(period)
+		if !nominal.After(now) {
+			missed := now.Sub(nominal)/period + 1
+			nominal = nominal.Add(missed * period)
+		}
+		return nominal.Sub(now)
+	}
+	r.start(period, period)
+	track(r)
+	runtime.SetFinalizer(t, (*Ticker).release)
+	return t
+}
+
+func clampFraction(f float64) float64 {
+	if f < 0 {
+		return 0
+	}
+	if f > 1 {
+		return 1
+	}
+	return f
+}
+
+type jitterSource struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func newJitterSource(seed int64) *jitterSource {
+	return &jitterSource{rnd: rand.New(rand.NewSource(seed))}
+}
+
+// duration returns random duration in [-fraction*base, fraction*base].
+func (s *jitterSource) duration(base time.Duration, fraction float64) time.Duration {
+	s.mu.Lock()
+	f := s.rnd.Float64()*2 - 1
+	s.mu.Unlock()
+	return time.Duration(f * fraction * float64(base))
+}
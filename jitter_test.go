@@ -0,0 +1,112 @@
+package realtime
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// withJitterSeed seeds jitter and returns function which restores previous
+// jitter source.
+func withJitterSeed(seed int64) func() {
+	prev := getConfig().jitter
+	Configure(WithJitterSeed(seed))
+	return func() {
+		Configure(func(c *config) {
+			c.jitter = prev
+		})
+	}
+}
+
+func jitteredDurations(seed int64) []time.Duration {
+	p, restore := useFakePoller()
+	defer restore()
+	defer withJitterSeed(seed)()
+
+	for i := 0; i < 5; i++ {
+		NewTimer(time.Second, WithJitter(0.5)).Stop()
+	}
+	return p.armedDurations()
+}
+
+func TestTimerJitterDeterministic(t *testing.T) {
+	first := jitteredDurations(42)
+	second := jitteredDurations(42)
+	if !reflect.DeepEqual(first, second) {
+		t.Fatalf("expected same durations for same seed, got %v and %v", first, second)
+	}
+	if reflect.DeepEqual(first, jitteredDurations(43)) {
+		t.Fatalf("expected different durations for different seed, got %v", first)
+	}
+
+	var distinct bool
+	for _, d := range first {
+		if d < 500*time.Millisecond || d > 1500*time.Millisecond {
+			t.Fatalf("jittered duration %s out of range", d)
+		}
+		if d != time.Second {
+			distinct = true
+		}
+	}
+	if !distinct {
+		t.Fatalf("expected jittered durations, got %v", first)
+	}
+}
+
+func TestTimerJitterNominalDeadline(t *testing.T) {
+	_, restore := useFakePoller()
+	defer restore()
+
+	start := Now()
+	timer := NewTimer(time.Hour, WithJitter(1))
+	defer timer.Stop()
+	if d := timer.Deadline().Sub(start); d < time.Hour || d > time.Hour+time.Second {
+		t.Fatalf("expected nominal deadline in an hour, got %s", d)
+	}
+}
+
+func TestJitteredTicker(t *testing.T) {
+	p, restore := useFakePoller()
+	defer restore()
+	defer withJitterSeed(1)()
+
+	const (
+		period = time.Hour
+		jitter = period / 10
+	)
+	start := Now()
+	ticker := NewJitteredTicker(period, 0.1)
+	defer ticker.Stop()
+
+	for i := 1; i <= 5; i++ {
+		p.advanceToNext()
+		tick := <-ticker.C
+		nominal := start.Add(time.Duration(i) * period)
+		if tick.Before(nominal.Add(-jitter)) || tick.After(nominal.Add(jitter)) {
+			t.Fatalf("tick %d at %s, expected %s +- %s", i, tick.Sub(start), nominal.Sub(start), jitter)
+		}
+	}
+
+	// Ticks missed during suspend are skipped.
+	p.advance(10 * period)
+	<-ticker.C
+	p.armedDurations()
+	p.advanceToNext()
+	<-ticker.C
+	if d := Now().Sub(start) % period; d > jitter && d < period-jitter {
+		t.Fatalf("tick after suspend is %s off nominal grid", d)
+	}
+}
+
+func TestJitteredAfterFunc(t *testing.T) {
+	p, restore := useFakePoller()
+	defer restore()
+
+	called := make(chan struct{})
+	AfterFunc(time.Minute, func() { close(called) }, WithJitter(0.2))
+	if d := p.armedDurations()[0]; d < 48*time.Second || d > 72*time.Second {
+		t.Fatalf("jittered duration %s out of range", d)
+	}
+	p.advance(2 * time.Minute)
+	<-called
+}
@@ -4,8 +4,8 @@ package realtime
 
 import (
 	"fmt"
-	"os"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -15,104 +15,162 @@ import (
 // TODO: Add retries for syscalls.
 // TODO: Make sure resources are released on exit.
 
-type timerHandler func()
-
 type kqueue struct {
-	fd         int
-	nextID     uint64
-	handlers   map[uint64]timerHandler
-	handlersMu sync.RWMutex
-	logger     func(msg ...interface{})
+	fd        int
+	nextIdent uint64
+	events    map[uint64]*event // by event id
+	idents    map[uint64]*event // by kevent ident
+	eventsMu  sync.Mutex
+	// closing is set by close handler on poll goroutine.
+	closing bool
+	exited  chan struct{}
 }
 
 func newKqueue() (*kqueue, error) {
 	fd, err := unix.Kqueue()
 	if err != nil {
+		logger().Log(LevelError, "kqueue failed", "backend", "kqueue", "err", err)
 		return nil, fmt.Errorf("could not create queue: %v", err)
 	}
 
-	logger := func(msg ...interface{}) {}
-	if os.Getenv("KQUEUE_DEBUG") == "1" {
-		logger = func(msg ...interface{}) {
-			fmt.Println("kqueue:", msg)
-		}
+	kq := &kqueue{
+		fd:     fd,
+		events: map[uint64]*event{},
+		idents: map[uint64]*event{},
+		exited: make(chan struct{}),
 	}
+	atomic.AddInt64(&stats.fdsInUse, 1)
+	kq.log(LevelDebug, "kqueue created", "fd", fd)
+	return kq, nil
+}
 
-	return &kqueue{
-		fd:       fd,
-		handlers: map[uint64]timerHandler{},
-		logger:   logger,
-	}, nil
+func (kq *kqueue) log(level Level, msg string, keyvals ...interface{}) {
+	logger().Log(level, msg, append([]interface{}{"backend", "kqueue"}, keyvals...)...)
 }
 
 func (kq *kqueue) eventsLen() int {
-	kq.handlersMu.Lock()
-	defer kq.handlersMu.Unlock()
-	return len(kq.handlers)
+	kq.eventsMu.Lock()
+	defer kq.eventsMu.Unlock()
+	return len(kq.events)
+}
+
+func (kq *kqueue) timers() []TimerInfo {
+	now := int64(nanotime())
+	kq.eventsMu.Lock()
+	defer kq.eventsMu.Unlock()
+	infos := make([]TimerInfo, 0, len(kq.events))
+	for _, e := range kq.events {
+		infos = append(infos, e.info(now))
+	}
+	return infos
 }
 
 func (kq *kqueue) registerTimerEvent(d time.Duration, handler timerHandler) (uint64, error) {
-	kq.logger("registerTimerEvent enter")
+	id := nextEventID()
+	return id, kq.registerEvent(id, timerEvent, d, 0, handler)
+}
+
+func (kq *kqueue) registerTickerEvent(d time.Duration, handler timerHandler) (uint64, error) {
+	id := nextEventID()
+	return id, kq.registerEvent(id, tickerEvent, d, d, handler)
+}
+
+// registerEvent arms event with given id. Already registered event is
+// replaced by kevent with new ident, so expirations of old one which were
+// already reported are ignored by poll.
+func (kq *kqueue) registerEvent(id uint64, kind eventKind, d, period time.Duration, handler timerHandler) error {
+	e := newEvent(id, kind, d, period, handler)
+	e.clock = "MACH_CONTINUOUS_TIME"
+
+	kq.eventsMu.Lock()
+	defer kq.eventsMu.Unlock()
 
-	kq.handlersMu.Lock()
-	kq.nextID++
-	id := kq.nextID
-	if _, ok := kq.handlers[id]; ok {
-		return 0, fmt.Errorf("kqueue: periodic event %d is already registered", id)
+	kq.nextIdent++
+	e.ident = kq.nextIdent
+	// Periodic kevent fires first after its period, so ticker with different
+	// phase starts as one shot kevent which is turned to periodic by poll.
+	kevent := newOneShotTimerEvent(e.ident, d)
+	if kind == tickerEvent && d == period {
+		kevent = newPeriodicTimerEvent(e.ident, period)
+	}
+	if err := kq.kevent(kevent); err != nil {
+		kq.log(LevelError, "kevent add failed", "id", id, "err", err)
+		return fmt.Errorf("could not create %s event %d: %v", kind, id, err)
 	}
-	kevent := newOneShotTimerEvent(id, d)
-	kq.handlers[id] = handler
-	kq.handlersMu.Unlock()
 
-	_, err := unix.Kevent(kq.fd, []unix.Kevent_t{kevent}, []unix.Kevent_t{}, nil)
-	if err != nil {
-		return 0, fmt.Errorf("could not create one time event %d: %v", id, err)
+	if old, ok := kq.events[id]; ok {
+		e.created, e.fires, e.missed = old.created, old.fires, old.missed
+		if err := kq.kevent(newDeleteEvent(old.ident)); err != nil {
+			kq.log(LevelWarn, "kevent delete failed", "id", id, "err", err)
+		}
+		delete(kq.idents, old.ident)
+		stats.eventRemoved(old.kind)
+		if logEnabled(LevelDebug) {
+			kq.log(LevelDebug, kind.String()+" reset", "id", id, "duration", d)
+		}
+	} else {
+		if logEnabled(LevelDebug) {
+			kq.log(LevelDebug, kind.String()+" registered", "id", id, "duration", d)
+		}
 	}
-	kq.logger("registerTimerEvent done", id)
-	return id, nil
+	kq.events[id] = e
+	kq.idents[e.ident] = e
+	stats.eventAdded(kind)
+	return nil
 }
 
-func (kq *kqueue) resetTimerEvent(id uint64, d time.Duration) error {
-	kevent := newOneShotTimerEvent(id, d)
-	_, err := unix.Kevent(kq.fd, []unix.Kevent_t{kevent}, []unix.Kevent_t{}, nil)
-	if err != nil {
-		return fmt.Errorf("could not update timer event %d: %v", id, err)
+func (kq *kqueue) deleteEvent(id uint64) error {
+	kq.eventsMu.Lock()
+	e, ok := kq.events[id]
+	if !ok {
+		kq.eventsMu.Unlock()
+		return nil
+	}
+	delete(kq.events, id)
+	delete(kq.idents, e.ident)
+	stats.eventRemoved(e.kind)
+	kq.eventsMu.Unlock()
+
+	if err := kq.kevent(newDeleteEvent(e.ident)); err != nil {
+		kq.log(LevelError, "kevent delete failed", "id", id, "err", err)
+		return fmt.Errorf("could not delete event %d: %v", id, err)
 	}
 	return nil
 }
 
-func (kq *kqueue) registerTickerEvent(d time.Duration, handler timerHandler) (uint64, error) {
-	kq.handlersMu.Lock()
-	kq.nextID++
-	id := kq.nextID
-	if _, ok := kq.handlers[id]; ok {
-		return 0, fmt.Errorf("kqueue: periodic event %d is already registered", id)
+func (kq *kqueue) remaining(id uint64) (time.Duration, error) {
+	kq.eventsMu.Lock()
+	defer kq.eventsMu.Unlock()
+	e, ok := kq.events[id]
+	if !ok {
+		return 0, nil
+	}
+	if d := time.Duration(e.deadline - int64(nanotime())); d > 0 {
+		return d, nil
 	}
-	kevent := newPeriodicTimerEvent(id, d)
-	kq.handlers[id] = handler
-	kq.handlersMu.Unlock()
+	return 0, nil
+}
 
-	_, err := unix.Kevent(kq.fd, []unix.Kevent_t{kevent}, []unix.Kevent_t{}, nil)
+// kevent applies single change. Deleting one shot timer which has already
+// fired is not an error.
+// close makes poll goroutine exit and waits until it released kqueue.
+// Timers of kqueue are released with it.
+func (kq *kqueue) close() {
+	err := kq.registerEvent(nextEventID(), timerEvent, 0, 0, func(uint64, uint64) {
+		kq.closing = true
+	})
 	if err != nil {
-		return 0, fmt.Errorf("could not create periodic event %d: %v", id, err)
+		return
 	}
-	return id, nil
+	<-kq.exited
 }
 
-func (kq *kqueue) deleteEvent(id uint64) error {
-	kq.handlersMu.Lock()
-	if _, ok := kq.handlers[id]; !ok {
-		kq.handlersMu.Unlock()
+func (kq *kqueue) kevent(change unix.Kevent_t) error {
+	_, err := unix.Kevent(kq.fd, []unix.Kevent_t{change}, []unix.Kevent_t{}, nil)
+	if err == unix.ENOENT && change.Flags&unix.EV_DELETE != 0 {
 		return nil
 	}
-	delete(kq.handlers, id)
-	kq.handlersMu.Unlock()
-
-	_, err := unix.Kevent(kq.fd, []unix.Kevent_t{newDeleteEvent(id)}, []unix.Kevent_t{}, nil)
-	if err != nil {
-		return fmt.Errorf("could not delete event %d: %v", id, err)
-	}
-	return nil
+	return err
 }
 
 func (kq *kqueue) poll(onError func(error)) {
@@ -122,18 +180,30 @@ func (kq *kqueue) poll(onError func(error)) {
 	)
 
 	defer func() {
+		kq.eventsMu.Lock()
+		for id, e := range kq.events {
+			delete(kq.events, id)
+			delete(kq.idents, e.ident)
+			stats.eventRemoved(e.kind)
+		}
+		kq.eventsMu.Unlock()
+		atomic.AddInt64(&stats.fdsInUse, -1)
 		if err := unix.Close(kq.fd); err != nil {
+			kq.log(LevelError, "close kqueue failed", "fd", kq.fd, "err", err)
 			onError(err)
 		}
+		close(kq.exited)
 	}()
 
 	events := make([]unix.Kevent_t, eventsLen)
+	fired := make([]*event, 0, eventsLen)
 	for {
 		n, err := unix.Kevent(kq.fd, []unix.Kevent_t{}, events, nil)
 		if err != nil {
 			if temporaryErr(err) {
 				continue
 			}
+			kq.log(LevelError, "kevent wait failed", "err", err)
 			onError(fmt.Errorf("could not get event: %v", err))
 			return
 		}
@@ -141,23 +211,60 @@ func (kq *kqueue) poll(onError func(error)) {
 		if n == 0 {
 			continue
 		}
+		stats.polled(n, len(events))
 
-		kq.handlersMu.Lock()
+		now := int64(nanotime())
+		fired = fired[:0]
+		wakeup := int64(-1)
+		kq.eventsMu.Lock()
 		for i := 0; i < n; i++ {
-			e := events[i]
-			handler := kq.handlers[e.Ident]
-			if handler != nil {
-				handler()
+			e, ok := kq.idents[events[i].Ident]
+			if !ok {
+				// Event was deleted or re-armed after it fired.
+				continue
 			}
 
-			if e.Flags&unix.EV_ONESHOT != 0 {
-				delete(kq.handlers, e.Ident)
+			if events[i].Flags&unix.EV_ONESHOT != 0 {
+				if e.kind == tickerEvent {
+					// First tick of phased ticker, continue periodically.
+					if err := kq.kevent(newPeriodicTimerEvent(e.ident, e.period)); err != nil {
+						kq.log(LevelError, "kevent add failed", "id", e.id, "err", err)
+					}
+				} else {
+					delete(kq.events, e.id)
+					delete(kq.idents, e.ident)
+					stats.eventRemoved(e.kind)
+				}
 			}
+			if late := stats.fired(e, now); wakeup < 0 || late < wakeup {
+				wakeup = late
+			}
+			// Data holds number of expirations since last report.
+			if missed := e.expire(uint64(events[i].Data)); missed > 0 {
+				atomic.AddUint64(&stats.tickerOverruns, missed)
+				if logEnabled(LevelWarn) {
+					kq.log(LevelWarn, "ticker overrun", "id", e.id, "missed", missed)
+				}
+			}
+			fired = append(fired, e)
+		}
+		kq.eventsMu.Unlock()
+		if wakeup >= 0 {
+			stats.woke(wakeup)
+		}
+
+		for _, e := range fired {
+			callHandler(e)
+		}
+		if kq.closing {
+			return
 		}
-		kq.handlersMu.Unlock()
 
 		if n == len(events) && n*2 <= maxEventsLen {
 			events = make([]unix.Kevent_t, n*2)
+			if logEnabled(LevelDebug) {
+				kq.log(LevelDebug, "events buffer grown", "len", len(events))
+			}
 		}
 	}
 }
@@ -14,19 +14,20 @@ func TestTimerEventCleanupAfterFire(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	defer queue.close()
 	go queue.poll(func(err error) {
 
 	})
 
 	var wg sync.WaitGroup
 	wg.Add(3)
-	queue.registerTimerEvent(10*time.Millisecond, func() {
+	queue.registerTimerEvent(10*time.Millisecond, func(uint64, uint64) {
 		wg.Done()
 	})
-	queue.registerTimerEvent(15*time.Millisecond, func() {
+	queue.registerTimerEvent(15*time.Millisecond, func(uint64, uint64) {
 		wg.Done()
 	})
-	queue.registerTimerEvent(20*time.Millisecond, func() {
+	queue.registerTimerEvent(20*time.Millisecond, func(uint64, uint64) {
 		wg.Done()
 	})
 
@@ -50,11 +51,12 @@ func TestTimerEventDeleteNotFiresEvent(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	defer queue.close()
 	go queue.poll(func(err error) {
 
 	})
 
-	id, err := queue.registerTimerEvent(100*time.Millisecond, func() {
+	id, err := queue.registerTimerEvent(100*time.Millisecond, func(uint64, uint64) {
 		t.Fatal("should not call callback")
 	})
 
@@ -68,12 +70,13 @@ func TestTickerEvent(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	defer queue.close()
 	go queue.poll(func(err error) {
 
 	})
 
 	var ticks int
-	id, err := queue.registerTickerEvent(100*time.Millisecond, func() {
+	id, err := queue.registerTickerEvent(100*time.Millisecond, func(uint64, uint64) {
 		ticks++
 	})
 
@@ -0,0 +1,154 @@
+package realtime
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WithTracking enables recording of creation call stacks for timers and
+// tickers. Tracking is required by Leaks and realtimetest.VerifyNone and
+// adds stacks to Timers output. Only timers created after enabling are tracked.
+func WithTracking(enabled bool) Option {
+	return func(c *config) {
+		c.tracking = enabled
+	}
+}
+
+// Leak is a ticker which was never stopped or a timer which was never
+// stopped or drained.
+type Leak struct {
+	ID    uint64
+	Kind  string
+	Age   time.Duration
+	Fired bool
+	Stack string
+}
+
+func (l Leak) String() string {
+	state := "pending"
+	if l.Fired {
+		state = "fired, not drained"
+	}
+	if l.Kind == tickerEvent.String() {
+		state = "not stopped"
+	}
+	return fmt.Sprintf("%s#%d created %s ago, %s\n%s", l.Kind, l.ID, l.Age, state, l.Stack)
+}
+
+type trackedTimer struct {
+	kind    eventKind
+	created Time
+	r       *timer
+	stack   string
+}
+
+var tracker = struct {
+	sync.Mutex
+	timers map[uint64]*trackedTimer
+}{
+	timers: map[uint64]*trackedTimer{},
+}
+
+func track(r *timer) {
+	if !getConfig().tracking {
+		return
+	}
+	tt := &trackedTimer{
+		kind:    r.kind,
+		created: Now(),
+		r:       r,
+		stack:   callerStack(),
+	}
+	r.tracked = true
+	tracker.Lock()
+	tracker.timers[r.id] = tt
+	tracker.Unlock()
+}
+
+// untrack skips the lock for timers which were created with tracking
+// disabled, Stop does not contend on it then.
+func untrack(r *timer) {
+	if !r.tracked {
+		return
+	}
+	tracker.Lock()
+	delete(tracker.timers, r.id)
+	tracker.Unlock()
+}
+
+func trackedStack(id uint64) string {
+	tracker.Lock()
+	defer tracker.Unlock()
+	if tt, ok := tracker.timers[id]; ok {
+		return tt.stack
+	}
+	return ""
+}
+
+// callerStack formats stack of goroutine skipping frames of this package.
+func callerStack() string {
+	pc := make([]uintptr, 32)
+	n := runtime.Callers(3, pc)
+	frames := runtime.CallersFrames(pc[:n])
+
+	var b strings.Builder
+	internal := true
+	for {
+		f, more := frames.Next()
+		if internal && strings.HasPrefix(f.Function, "github.com/anjmao/realtime.") && !strings.HasSuffix(f.File, "_test.go") {
+			if !more {
+				break
+			}
+			continue
+		}
+		internal = false
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", f.Function, f.File, f.Line)
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
+
+// Leaks returns tracked tickers which were never stopped and timers which
+// were neither stopped nor drained and are older than olderThan. Tracking
+// must be enabled with WithTracking.
+func Leaks(olderThan time.Duration) []Leak {
+	live := map[uint64]bool{}
+	for _, info := range liveTimers() {
+		live[info.ID] = true
+	}
+
+	now := Now()
+	var leaks []Leak
+	tracker.Lock()
+	for id, tt := range tracker.timers {
+		fired := !live[id]
+		if tt.kind == timerEvent && fired && !tt.r.unreceived() {
+			// Timer fired and value was received.
+			delete(tracker.timers, id)
+			continue
+		}
+		age := now.Sub(tt.created)
+		if age < olderThan {
+			continue
+		}
+		leaks = append(leaks, Leak{
+			ID:    id,
+			Kind:  tt.kind.String(),
+			Age:   age,
+			Fired: fired,
+			Stack: tt.stack,
+		})
+	}
+	tracker.Unlock()
+
+	sort.Slice(leaks, func(i, j int) bool {
+		return leaks[i].ID < leaks[j].ID
+	})
+	return leaks
+}
@@ -0,0 +1,75 @@
+package realtime
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLeaks(t *testing.T) {
+	Configure(WithTracking(true))
+	defer Configure(WithTracking(false))
+
+	ticker := NewTicker(time.Hour)
+	timer := NewTimer(time.Millisecond)
+	stopped := NewTicker(time.Hour)
+	stopped.Stop()
+	<-After(time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+
+	leaks := Leaks(0)
+	if len(leaks) != 2 {
+		t.Fatalf("expected 2 leaks, got %v", leaks)
+	}
+	if leaks[0].ID != ticker.r.id || leaks[0].Kind != "ticker" {
+		t.Fatalf("expected ticker leak, got %v", leaks[0])
+	}
+	if !strings.Contains(leaks[0].Stack, "TestLeaks") {
+		t.Fatalf("expected stack to contain test function, got %s", leaks[0].Stack)
+	}
+	if leaks[1].ID != timer.r.id || !leaks[1].Fired {
+		t.Fatalf("expected fired timer leak, got %v", leaks[1])
+	}
+
+	if leaks := Leaks(time.Hour); len(leaks) != 0 {
+		t.Fatalf("expected no leaks older than hour, got %v", leaks)
+	}
+
+	ticker.Stop()
+	<-timer.C
+	// Sender notices receive shortly after it happens.
+	deadline := time.Now().Add(time.Second)
+	for len(Leaks(0)) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected no leaks, got %v", Leaks(0))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLeaksModernTimers(t *testing.T) {
+	Configure(WithTracking(true))
+	defer Configure(WithTracking(false))
+	defer withModernTimers()()
+
+	timer := NewTimer(time.Millisecond)
+	received := NewTimer(time.Millisecond)
+	<-received.C
+	time.Sleep(10 * time.Millisecond)
+
+	// Unbuffered channel is always empty, fired timer is pending until its
+	// value is received.
+	leaks := Leaks(0)
+	if len(leaks) != 1 || leaks[0].ID != timer.r.id || !leaks[0].Fired {
+		t.Fatalf("expected fired timer leak, got %v", leaks)
+	}
+	<-timer.C
+	// Sender notices receive shortly after it happens.
+	deadline := time.Now().Add(time.Second)
+	for len(Leaks(0)) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected no leaks, got %v", Leaks(0))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,110 @@
+package realtime
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Level is a severity of a log record.
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	}
+	return fmt.Sprintf("level(%d)", int(l))
+}
+
+// Logger receives structured records from the timer engine. Keyvals are
+// alternating keys and values, keys are always strings.
+type Logger interface {
+	Log(level Level, msg string, keyvals ...interface{})
+}
+
+// LevelEnabler may be implemented by Logger to report whether it records
+// level, the engine then does not build records which would be dropped.
+type LevelEnabler interface {
+	Enabled(level Level) bool
+}
+
+// NewTextLogger returns a Logger which writes records with level at least
+// min to w in logfmt-like format.
+func NewTextLogger(w io.Writer, min Level) Logger {
+	return &textLogger{w: w, min: min}
+}
+
+type textLogger struct {
+	mu  sync.Mutex
+	w   io.Writer
+	min Level
+}
+
+func (l *textLogger) Log(level Level, msg string, keyvals ...interface{}) {
+	if level < l.min {
+		return
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "level=%s msg=%q", level, msg)
+	for i := 0; i < len(keyvals); i += 2 {
+		var v interface{} = "(MISSING)"
+		if i+1 < len(keyvals) {
+			v = keyvals[i+1]
+		}
+		fmt.Fprintf(&b, " %v=%v", keyvals[i], v)
+	}
+	b.WriteByte('\n')
+
+	l.mu.Lock()
+	io.WriteString(l.w, b.String())
+	l.mu.Unlock()
+}
+
+func (l *textLogger) Enabled(level Level) bool {
+	return level >= l.min
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Log(level Level, msg string, keyvals ...interface{}) {}
+
+func (nopLogger) Enabled(level Level) bool {
+	return false
+}
+
+// logEnabled reports whether configured logger records level. Hot paths
+// check it before logging, building keyvals allocates.
+func logEnabled(level Level) bool {
+	if l, ok := logger().(LevelEnabler); ok {
+		return l.Enabled(level)
+	}
+	return true
+}
+
+// defaultLogger maps legacy EPOLL_DEBUG and KQUEUE_DEBUG environment
+// variables onto a debug level text logger.
+func defaultLogger() Logger {
+	for _, env := range []string{"EPOLL_DEBUG", "KQUEUE_DEBUG"} {
+		if os.Getenv(env) == "1" {
+			return NewTextLogger(os.Stdout, LevelDebug)
+		}
+	}
+	return nopLogger{}
+}
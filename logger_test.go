@@ -0,0 +1,81 @@
+package realtime
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordLogger struct {
+	mu      sync.Mutex
+	records []string
+}
+
+func (l *recordLogger) Log(level Level, msg string, keyvals ...interface{}) {
+	l.mu.Lock()
+	l.records = append(l.records, msg)
+	l.mu.Unlock()
+}
+
+func (l *recordLogger) has(msg string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, r := range l.records {
+		if r == msg {
+			return true
+		}
+	}
+	return false
+}
+
+func TestTextLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewTextLogger(&buf, LevelInfo)
+	l.Log(LevelDebug, "skipped")
+	l.Log(LevelWarn, "clock fallback", "from", "CLOCK_BOOTTIME", "fd")
+
+	expected := "level=warn msg=\"clock fallback\" from=CLOCK_BOOTTIME fd=(MISSING)\n"
+	if actual := buf.String(); actual != expected {
+		t.Fatalf("expected %q, got %q", expected, actual)
+	}
+}
+
+func TestConfigureLogger(t *testing.T) {
+	l := &recordLogger{}
+	Configure(WithLogger(l))
+	defer Configure(WithLogger(defaultLogger()))
+
+	<-After(time.Millisecond)
+	if !l.has("timer registered") {
+		l.mu.Lock()
+		records := append([]string(nil), l.records...)
+		l.mu.Unlock()
+		t.Fatalf("expected timer registered record, got %v", records)
+	}
+}
+
+func TestLogEnabled(t *testing.T) {
+	defer Configure(WithLogger(defaultLogger()))
+
+	Configure(WithLogger(NewTextLogger(&bytes.Buffer{}, LevelWarn)))
+	if logEnabled(LevelDebug) || !logEnabled(LevelWarn) {
+		t.Fatal("expected text logger to record only warn and above")
+	}
+	Configure(WithLogger(nil))
+	if logEnabled(LevelError) {
+		t.Fatal("expected nil logger to record nothing")
+	}
+	// Loggers which do not implement LevelEnabler get all records.
+	Configure(WithLogger(&recordLogger{}))
+	if !logEnabled(LevelDebug) {
+		t.Fatal("expected record logger to record debug")
+	}
+}
+
+func BenchmarkAfterFuncStop(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		AfterFunc(time.Hour, nil).Stop()
+	}
+}
@@ -0,0 +1,203 @@
+package realtime
+
+import (
+	"expvar"
+	"sync/atomic"
+	"time"
+)
+
+// StatsSnapshot is a snapshot of timer engine metrics.
+type StatsSnapshot struct {
+	ActiveTimers   int64
+	ActiveTickers  int64
+	FDsInUse       int64
+	Fires          uint64
+	Stops          uint64
+	Resets         uint64
+	DroppedSends   uint64
+	TickerOverruns uint64
+	PollWakeups    uint64
+	EventsBuffer   int64
+	HandlerPanics  uint64
+	// ExecutorQueued is number of callbacks waiting for pool executor worker.
+	ExecutorQueued int64
+	// ExecutorSaturations is number of callbacks which found pool executor
+	// queue full.
+	ExecutorSaturations uint64
+	// ExecutorDropped is number of callbacks dropped by pool executor with
+	// DropOverflow policy.
+	ExecutorDropped uint64
+	// SavedWakeups is number of timer expirations delivered by wakeups of
+	// other timers because of slack.
+	SavedWakeups uint64
+	// PollerLocked reports whether LockPollerThread locked poller to OS
+	// thread and applied all its settings.
+	PollerLocked bool
+	EventBatch   HistogramSnapshot
+	FireLateness HistogramSnapshot
+	// WakeupLatency is delay between earliest deadline and poller wakeup.
+	WakeupLatency HistogramSnapshot
+}
+
+// HistogramSnapshot is a cumulative histogram. Counts[i] is the number of
+// observations less than or equal to Bounds[i], the last count includes all
+// observations.
+type HistogramSnapshot struct {
+	Bounds []float64
+	Counts []uint64
+	Count  uint64
+	Sum    float64
+}
+
+type histogram struct {
+	bounds []float64
+	counts []uint64 // per bucket, last bucket is +Inf
+	count  uint64
+	sum    uint64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{
+		bounds: bounds,
+		counts: make([]uint64, len(bounds)+1),
+	}
+}
+
+func (h *histogram) observe(v uint64) {
+	i := 0
+	for ; i < len(h.bounds); i++ {
+		if float64(v) <= h.bounds[i] {
+			break
+		}
+	}
+	atomic.AddUint64(&h.counts[i], 1)
+	atomic.AddUint64(&h.count, 1)
+	atomic.AddUint64(&h.sum, v)
+}
+
+func (h *histogram) snapshot() HistogramSnapshot {
+	s := HistogramSnapshot{
+		Bounds: append([]float64(nil), h.bounds...),
+		Counts: make([]uint64, len(h.counts)),
+		Count:  atomic.LoadUint64(&h.count),
+		Sum:    float64(atomic.LoadUint64(&h.sum)),
+	}
+	var cumulative uint64
+	for i := range h.counts {
+		cumulative += atomic.LoadUint64(&h.counts[i])
+		s.Counts[i] = cumulative
+	}
+	return s
+}
+
+type metrics struct {
+	activeTimers   int64
+	activeTickers  int64
+	fdsInUse       int64
+	fires          uint64
+	stops          uint64
+	resets         uint64
+	droppedSends   uint64
+	tickerOverruns uint64
+	pollWakeups    uint64
+	eventsBuffer   int64
+	handlerPanics  uint64
+
+	executorQueued      int64
+	executorSaturations uint64
+	executorDropped     uint64
+	savedWakeups        uint64
+	pollerLocked        int64
+
+	eventBatch    *histogram
+	fireLateness  *histogram // nanoseconds
+	wakeupLatency *histogram // nanoseconds
+}
+
+var latencyBounds = []float64{
+	float64(time.Microsecond),
+	float64(10 * time.Microsecond),
+	float64(100 * time.Microsecond),
+	float64(time.Millisecond),
+	float64(10 * time.Millisecond),
+	float64(100 * time.Millisecond),
+	float64(time.Second),
+	float64(10 * time.Second),
+}
+
+var stats = &metrics{
+	eventBatch:    newHistogram([]float64{1, 2, 4, 8, 16, 32, 64, 128, 256, 512, 1024, 4096, 32768}),
+	fireLateness:  newHistogram(latencyBounds),
+	wakeupLatency: newHistogram(latencyBounds),
+}
+
+func init() {
+	expvar.Publish("realtime", expvar.Func(func() interface{} {
+		return Stats()
+	}))
+}
+
+func (m *metrics) eventAdded(kind eventKind) {
+	if kind == tickerEvent {
+		atomic.AddInt64(&m.activeTickers, 1)
+	} else {
+		atomic.AddInt64(&m.activeTimers, 1)
+	}
+}
+
+func (m *metrics) eventRemoved(kind eventKind) {
+	if kind == tickerEvent {
+		atomic.AddInt64(&m.activeTickers, -1)
+	} else {
+		atomic.AddInt64(&m.activeTimers, -1)
+	}
+}
+
+// fired records expiration of event and returns its lateness.
+func (m *metrics) fired(e *event, now int64) int64 {
+	atomic.AddUint64(&m.fires, 1)
+	late := now - e.deadline
+	if late < 0 {
+		late = 0
+	}
+	m.fireLateness.observe(uint64(late))
+	return late
+}
+
+// woke records latency of poller wakeup, that is lateness of the earliest
+// event it returned.
+func (m *metrics) woke(latency int64) {
+	m.wakeupLatency.observe(uint64(latency))
+}
+
+func (m *metrics) polled(n int, eventsLen int) {
+	atomic.AddUint64(&m.pollWakeups, 1)
+	atomic.StoreInt64(&m.eventsBuffer, int64(eventsLen))
+	m.eventBatch.observe(uint64(n))
+}
+
+// Stats returns snapshot of timer engine metrics.
+func Stats() StatsSnapshot {
+	return StatsSnapshot{
+		ActiveTimers:   atomic.LoadInt64(&stats.activeTimers),
+		ActiveTickers:  atomic.LoadInt64(&stats.activeTickers),
+		FDsInUse:       atomic.LoadInt64(&stats.fdsInUse),
+		Fires:          atomic.LoadUint64(&stats.fires),
+		Stops:          atomic.LoadUint64(&stats.stops),
+		Resets:         atomic.LoadUint64(&stats.resets),
+		DroppedSends:   atomic.LoadUint64(&stats.droppedSends),
+		TickerOverruns: atomic.LoadUint64(&stats.tickerOverruns),
+		PollWakeups:    atomic.LoadUint64(&stats.pollWakeups),
+		EventsBuffer:   atomic.LoadInt64(&stats.eventsBuffer),
+		HandlerPanics:  atomic.LoadUint64(&stats.handlerPanics),
+
+		ExecutorQueued:      atomic.LoadInt64(&stats.executorQueued),
+		ExecutorSaturations: atomic.LoadUint64(&stats.executorSaturations),
+		ExecutorDropped:     atomic.LoadUint64(&stats.executorDropped),
+		SavedWakeups:        atomic.LoadUint64(&stats.savedWakeups),
+		PollerLocked:        atomic.LoadInt64(&stats.pollerLocked) == 1,
+		EventBatch:          stats.eventBatch.snapshot(),
+		FireLateness:        stats.fireLateness.snapshot(),
+		WakeupLatency:       stats.wakeupLatency.snapshot(),
+	}
+}
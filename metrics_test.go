@@ -0,0 +1,59 @@
+package realtime
+
+import (
+	"bytes"
+	"expvar"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	before := Stats()
+
+	<-After(time.Millisecond)
+	NewTimer(time.Hour).Stop()
+
+	after := Stats()
+	if after.Fires <= before.Fires {
+		t.Fatalf("expected fires to grow from %d, got %d", before.Fires, after.Fires)
+	}
+	if after.Stops <= before.Stops {
+		t.Fatalf("expected stops to grow from %d, got %d", before.Stops, after.Stops)
+	}
+	if after.FireLateness.Count <= before.FireLateness.Count {
+		t.Fatalf("expected lateness observations to grow from %d, got %d", before.FireLateness.Count, after.FireLateness.Count)
+	}
+	if after.FDsInUse != before.FDsInUse {
+		t.Fatalf("expected %d fds in use, got %d", before.FDsInUse, after.FDsInUse)
+	}
+}
+
+func TestExpvar(t *testing.T) {
+	v := expvar.Get("realtime")
+	if v == nil {
+		t.Fatal("expected realtime expvar to be published")
+	}
+	if !strings.Contains(v.String(), "ActiveTimers") {
+		t.Fatalf("unexpected expvar value %s", v)
+	}
+}
+
+func TestWritePrometheus(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WritePrometheus(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	for _, expected := range []string{
+		"# TYPE realtime_active_timers gauge\n",
+		"# TYPE realtime_fires_total counter\n",
+		"realtime_fire_lateness_seconds_bucket{le=\"+Inf\"}",
+		"realtime_event_batch_size_count",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("expected output to contain %q, got\n%s", expected, out)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// Package netx enforces net.Conn deadlines with realtime timers. Runtime
+// poller measures deadlines on monotonic clock which stops during suspend, so
+// deadline set before suspend is extended by its duration.
+package netx
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/anjmao/realtime"
+)
+
+// aLongTimeAgo is a deadline in the past which fails blocked calls.
+var aLongTimeAgo = time.Unix(1, 0)
+
+// Conn is a net.Conn whose deadlines expire by realtime clock. On expiry
+// deadline of the underlying connection is moved to the past, so blocked
+// and later calls fail with its timeout error, os.ErrDeadlineExceeded since
+// Go 1.15.
+type Conn struct {
+	net.Conn
+
+	mu    sync.Mutex
+	read  deadline
+	write deadline
+}
+
+// deadline is a read or write deadline armed as realtime timer.
+type deadline struct {
+	set   func(time.Time) error
+	timer *realtime.Timer
+	armed bool
+	at    realtime.Time
+}
+
+// WrapConn returns c with deadlines enforced by realtime timers.
+func WrapConn(c net.Conn) *Conn {
+	wc := &Conn{Conn: c}
+	wc.read.set = c.SetReadDeadline
+	wc.write.set = c.SetWriteDeadline
+	return wc
+}
+
+// SetDeadline sets read and write deadlines, zero value disables them.
+func (c *Conn) SetDeadline(t time.Time) error {
+	if err := c.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.SetWriteDeadline(t)
+}
+
+// SetReadDeadline sets read deadline, zero value disables it. Time until
+// deadline is measured when it is set.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.arm(&c.read, t)
+}
+
+// SetWriteDeadline sets write deadline, zero value disables it. Time until
+// deadline is measured when it is set.
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.arm(&c.write, t)
+}
+
+// Close stops deadline timers and closes connection.
+func (c *Conn) Close() error {
+	c.mu.Lock()
+	c.read.stop()
+	c.write.stop()
+	c.mu.Unlock()
+	return c.Conn.Close()
+}
+
+func (c *Conn) arm(dl *deadline, t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	dl.stop()
+	if t.IsZero() {
+		return dl.set(time.Time{})
+	}
+	d := time.Until(t)
+	if d <= 0 {
+		return dl.set(aLongTimeAgo)
+	}
+	// Underlying deadline is cleared, realtime timer moves it to the past.
+	if err := dl.set(time.Time{}); err != nil {
+		return err
+	}
+	dl.armed = true
+	dl.at = realtime.Now().Add(d)
+	if dl.timer == nil {
+		dl.timer = realtime.AfterFunc(d, func() {
+			c.expire(dl)
+		})
+	} else {
+		dl.timer.Reset(d)
+	}
+	return nil
+}
+
+func (c *Conn) expire(dl *deadline) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !dl.armed || realtime.Now().Before(dl.at) {
+		// Deadline was changed after timer expired.
+		return
+	}
+	dl.armed = false
+	dl.set(aLongTimeAgo)
+}
+
+// stop must be called with mu held.
+func (dl *deadline) stop() {
+	dl.armed = false
+	if dl.timer != nil {
+		dl.timer.Stop()
+	}
+}
+
+// Listener wraps accepted connections with WrapConn.
+type Listener struct {
+	net.Listener
+}
+
+// WrapListener returns l which wraps accepted connections with WrapConn.
+func WrapListener(l net.Listener) *Listener {
+	return &Listener{Listener: l}
+}
+
+// Accept waits for the next connection and returns it as *Conn.
+func (l *Listener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return WrapConn(c), nil
+}
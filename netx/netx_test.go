@@ -0,0 +1,100 @@
+package netx
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func expectTimeout(t *testing.T, err error) {
+	t.Helper()
+	if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+}
+
+func TestReadDeadline(t *testing.T) {
+	a, b := net.Pipe()
+	defer b.Close()
+	c := WrapConn(a)
+	defer c.Close()
+
+	const d = 50 * time.Millisecond
+	start := time.Now()
+	if err := c.SetReadDeadline(start.Add(d)); err != nil {
+		t.Fatal(err)
+	}
+	_, err := c.Read(make([]byte, 1))
+	expectTimeout(t, err)
+	if elapsed := time.Since(start); elapsed < d {
+		t.Fatalf("Read failed after %s, before %s deadline", elapsed, d)
+	}
+
+	// Later calls fail until deadline is changed.
+	_, err = c.Read(make([]byte, 1))
+	expectTimeout(t, err)
+	c.SetReadDeadline(time.Time{})
+	go b.Write([]byte("x"))
+	if _, err := c.Read(make([]byte, 1)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWriteDeadline(t *testing.T) {
+	a, b := net.Pipe()
+	defer b.Close()
+	c := WrapConn(a)
+	defer c.Close()
+
+	c.SetDeadline(time.Now().Add(20 * time.Millisecond))
+	_, err := c.Write([]byte("x"))
+	expectTimeout(t, err)
+
+	c.SetWriteDeadline(time.Now().Add(-time.Second))
+	_, err = c.Write([]byte("x"))
+	expectTimeout(t, err)
+}
+
+func TestDeadlineExtended(t *testing.T) {
+	a, b := net.Pipe()
+	defer b.Close()
+	c := WrapConn(a)
+	defer c.Close()
+
+	c.SetReadDeadline(time.Now().Add(20 * time.Millisecond))
+	c.SetReadDeadline(time.Now().Add(time.Hour))
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		b.Write([]byte("x"))
+	}()
+	if _, err := c.Read(make([]byte, 1)); err != nil {
+		t.Fatalf("expected extended deadline to allow Read, got %v", err)
+	}
+}
+
+func TestListener(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wl := WrapListener(l)
+	defer wl.Close()
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	conn, err := wl.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, ok := conn.(*Conn); !ok {
+		t.Fatalf("expected accepted connection to be *Conn, got %T", conn)
+	}
+	conn.SetReadDeadline(time.Now().Add(20 * time.Millisecond))
+	_, err = conn.Read(make([]byte, 1))
+	expectTimeout(t, err)
+}
@@ -0,0 +1,67 @@
+package realtime
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Option configures the timer engine.
+type Option func(*config)
+
+type config struct {
+	logger    Logger
+	tracking  bool
+	executor  Executor
+	panicHook func(HandlerPanic)
+
+	modernTimers bool
+	jitter       *jitterSource
+	shards       int
+}
+
+// WithLogger sets logger which receives engine records. Nil logger disables
+// logging.
+func WithLogger(l Logger) Option {
+	return func(c *config) {
+		if l == nil {
+			l = nopLogger{}
+		}
+		c.logger = l
+	}
+}
+
+var (
+	configMu      sync.Mutex
+	currentConfig atomic.Value
+)
+
+func init() {
+	currentConfig.Store(&config{
+		logger:   defaultLogger(),
+		executor: SpawnExecutor(),
+		jitter:   newJitterSource(time.Now().UnixNano()),
+		shards:   shardsFromEnv(),
+	})
+}
+
+// Configure applies options to the timer engine. It is safe to call
+// concurrently with timers being used.
+func Configure(opts ...Option) {
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	c := *getConfig()
+	for _, opt := range opts {
+		opt(&c)
+	}
+	currentConfig.Store(&c)
+}
+
+func getConfig() *config {
+	return currentConfig.Load().(*config)
+}
+
+func logger() Logger {
+	return getConfig().logger
+}
@@ -0,0 +1,185 @@
+package realtime
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	minSpin = 2 * time.Microsecond
+	maxSpin = time.Millisecond
+)
+
+// WithPrecise arms timer or ticker early and busy-waits on the clock for the
+// rest of the delay, trading CPU time for wakeup accuracy. Busy-wait duration
+// is calibrated from observed wakeup latency.
+func WithPrecise() TimerOption {
+	return func(r *timer) {
+		r.precise = true
+	}
+}
+
+// PreciseSleep pauses the current goroutine for at least the duration d like
+// Sleep, but sleeps only until d minus calibrated wakeup latency and
+// busy-waits for the rest.
+func PreciseSleep(d time.Duration) {
+	deadline := int64(nanotime()) + int64(d)
+	spin := calibration.spin()
+	if early := d - spin; early > 0 {
+		<-newTimer(early).C
+		calibration.observe(time.Duration(int64(nanotime()) - deadline + int64(spin)))
+	}
+	spinUntil(deadline)
+}
+
+// spinner busy-waits for expirations of one precise timer on a single
+// goroutine, so poller is not blocked and expirations are handled in order.
+// Goroutine of ticker spinner lives until timer is stopped, goroutine of one
+// shot timer exits once there is nothing to handle.
+type spinner struct {
+	mu      sync.Mutex
+	pending []spinExpiry
+	running bool
+	linger  bool
+	wake    chan struct{}
+	quit    chan struct{}
+}
+
+type spinExpiry struct {
+	deadline    int64
+	id          uint64
+	expirations uint64
+	handler     timerHandler
+}
+
+func newSpinner(linger bool) *spinner {
+	return &spinner{
+		linger: linger,
+		wake:   make(chan struct{}, 1),
+		quit:   make(chan struct{}),
+	}
+}
+
+// handler returns early delay to arm event with and handler which passes
+// expirations to spinner, which busy-waits until nominal expiration before
+// calling handler.
+func (s *spinner) handler(d, period time.Duration, handler timerHandler) (time.Duration, timerHandler) {
+	spin := calibration.spin()
+	if spin > d {
+		spin = d
+	}
+	next := int64(nanotime()) + int64(d)
+	return d - spin, func(id, expirations uint64) {
+		if expirations == 0 {
+			expirations = 1
+		}
+		deadline := next + int64(expirations-1)*int64(period)
+		next = deadline + int64(period)
+		if expirations == 1 {
+			calibration.observe(time.Duration(int64(nanotime()) - deadline + int64(spin)))
+		}
+		s.add(spinExpiry{deadline: deadline, id: id, expirations: expirations, handler: handler})
+	}
+}
+
+func (s *spinner) add(e spinExpiry) {
+	s.mu.Lock()
+	s.pending = append(s.pending, e)
+	if !s.running {
+		s.running = true
+		go s.run()
+	}
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *spinner) run() {
+	for {
+		s.mu.Lock()
+		if len(s.pending) == 0 {
+			if !s.linger {
+				s.running = false
+				s.mu.Unlock()
+				return
+			}
+			s.mu.Unlock()
+			select {
+			case <-s.wake:
+				continue
+			case <-s.quit:
+				return
+			}
+		}
+		e := s.pending[0]
+		s.pending[0] = spinExpiry{}
+		s.pending = s.pending[1:]
+		s.mu.Unlock()
+
+		select {
+		case <-s.quit:
+			return
+		default:
+		}
+		spinUntil(e.deadline)
+		e.handler(e.id, e.expirations)
+	}
+}
+
+// stop makes goroutine exit, expirations which were not handled yet are
+// dropped.
+func (s *spinner) stop() {
+	close(s.quit)
+}
+
+// spinUntil busy-waits until deadline on boot clock. Boot clock is read only
+// once, reading it is a syscall. Spinning runs on runtime monotonic clock,
+// which is read through vDSO.
+func spinUntil(deadline int64) {
+	d := time.Duration(deadline - int64(nanotime()))
+	start := time.Now()
+	for time.Since(start) < d {
+	}
+}
+
+// spinCalibration estimates wakeup latency the same way TCP estimates round
+// trip time, busy-wait covers mean latency and four mean deviations.
+type spinCalibration struct {
+	mu  sync.Mutex
+	avg time.Duration
+	dev time.Duration
+}
+
+var calibration = &spinCalibration{
+	avg: 50 * time.Microsecond,
+	dev: 25 * time.Microsecond,
+}
+
+func (c *spinCalibration) observe(latency time.Duration) {
+	if latency < 0 {
+		latency = 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	diff := latency - c.avg
+	c.avg += diff / 8
+	if diff < 0 {
+		diff = -diff
+	}
+	c.dev += (diff - c.dev) / 4
+}
+
+func (c *spinCalibration) spin() time.Duration {
+	c.mu.Lock()
+	spin := c.avg + 4*c.dev
+	c.mu.Unlock()
+	if spin < minSpin {
+		return minSpin
+	}
+	if spin > maxSpin {
+		return maxSpin
+	}
+	return spin
+}
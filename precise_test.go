@@ -0,0 +1,122 @@
+package realtime
+
+import (
+	"runtime"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestPreciseSleep(t *testing.T) {
+	const d = 200 * time.Microsecond
+	for i := 0; i < 20; i++ {
+		start := Now()
+		PreciseSleep(d)
+		if slept := Since(start); slept < d {
+			t.Fatalf("PreciseSleep(%s) slept for only %s", d, slept)
+		}
+	}
+}
+
+func TestPreciseTicker(t *testing.T) {
+	const period = 2 * time.Millisecond
+	start := Now()
+	ticker := NewTicker(period, WithPrecise())
+	defer ticker.Stop()
+	for i := 1; i <= 5; i++ {
+		tick := <-ticker.C
+		if min := start.Add(time.Duration(i) * period); tick.Before(min) {
+			t.Fatalf("tick %d at %s before %s", i, tick.Sub(start), min.Sub(start))
+		}
+	}
+}
+
+func TestPreciseTimerStop(t *testing.T) {
+	timer := NewTimer(time.Hour, WithPrecise())
+	if !timer.Stop() {
+		t.Fatal("Stop of active precise timer = false, want true")
+	}
+	timer = NewTimer(time.Millisecond, WithPrecise())
+	<-timer.C
+}
+
+func TestSpinnerOrder(t *testing.T) {
+	s := newSpinner(true)
+	defer s.stop()
+	const n = 100
+	handled := make(chan uint64, n)
+	handler := func(id, _ uint64) {
+		handled <- id
+	}
+	deadline := int64(nanotime())
+	for i := uint64(0); i < n; i++ {
+		s.add(spinExpiry{deadline: deadline, id: i, expirations: 1, handler: handler})
+	}
+	for i := uint64(0); i < n; i++ {
+		if id := <-handled; id != i {
+			t.Fatalf("expected expiration %d, got %d", i, id)
+		}
+	}
+}
+
+func TestPreciseTickerGoroutines(t *testing.T) {
+	startEngine()
+	before := runtime.NumGoroutine()
+	ticker := NewTicker(time.Millisecond, WithPrecise())
+	for i := 0; i < 20; i++ {
+		<-ticker.C
+		// Spinner goroutine of ticker is reused for every tick.
+		if n := runtime.NumGoroutine(); n > before+1 {
+			t.Fatalf("expected at most %d goroutines, got %d", before+1, n)
+		}
+	}
+	ticker.Stop()
+	waitFor(t, func() bool { return runtime.NumGoroutine() == before })
+}
+
+func TestSpinCalibration(t *testing.T) {
+	c := &spinCalibration{avg: 50 * time.Microsecond, dev: 25 * time.Microsecond}
+	for i := 0; i < 200; i++ {
+		c.observe(10 * time.Microsecond)
+	}
+	if spin := c.spin(); spin < 10*time.Microsecond || spin > 12*time.Microsecond {
+		t.Fatalf("expected spin close to 10µs, got %s", spin)
+	}
+	for i := 0; i < 200; i++ {
+		c.observe(-time.Microsecond)
+	}
+	if spin := c.spin(); spin != minSpin {
+		t.Fatalf("expected spin %s, got %s", minSpin, spin)
+	}
+	for i := 0; i < 200; i++ {
+		c.observe(time.Second)
+	}
+	if spin := c.spin(); spin != maxSpin {
+		t.Fatalf("expected spin %s, got %s", maxSpin, spin)
+	}
+}
+
+func benchmarkAccuracy(b *testing.B, sleep func(time.Duration)) {
+	const d = 100 * time.Microsecond
+	errs := make([]time.Duration, b.N)
+	for i := range errs {
+		start := Now()
+		sleep(d)
+		errs[i] = Since(start) - d
+	}
+	b.StopTimer()
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i] < errs[j]
+	})
+	b.ReportMetric(float64(errs[len(errs)*50/100]), "p50-ns")
+	b.ReportMetric(float64(errs[len(errs)*99/100]), "p99-ns")
+	b.ReportMetric(float64(errs[len(errs)-1]), "max-ns")
+}
+
+func BenchmarkSleepAccuracy(b *testing.B) {
+	benchmarkAccuracy(b, Sleep)
+}
+
+func BenchmarkPreciseSleepAccuracy(b *testing.B) {
+	benchmarkAccuracy(b, PreciseSleep)
+}
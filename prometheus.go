@@ -0,0 +1,70 @@
+package realtime
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// PrometheusHandler returns http handler which serves engine metrics in
+// Prometheus text exposition format.
+func PrometheusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WritePrometheus(w)
+	})
+}
+
+// WritePrometheus writes engine metrics in Prometheus text exposition format.
+func WritePrometheus(w io.Writer) error {
+	s := Stats()
+	bw := bufio.NewWriter(w)
+
+	gauge := func(name, help string, v int64) {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s gauge\n%s %d\n", name, help, name, name, v)
+	}
+	counter := func(name, help string, v uint64) {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, v)
+	}
+	histogram := func(name, help string, h HistogramSnapshot, scale float64) {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+		for i, c := range h.Counts {
+			le := "+Inf"
+			if i < len(h.Bounds) {
+				le = strconv.FormatFloat(h.Bounds[i]/scale, 'g', -1, 64)
+			}
+			fmt.Fprintf(bw, "%s_bucket{le=%q} %d\n", name, le, c)
+		}
+		fmt.Fprintf(bw, "%s_sum %s\n", name, strconv.FormatFloat(h.Sum/scale, 'g', -1, 64))
+		fmt.Fprintf(bw, "%s_count %d\n", name, h.Count)
+	}
+
+	gauge("realtime_active_timers", "Number of armed one shot timers.", s.ActiveTimers)
+	gauge("realtime_active_tickers", "Number of running tickers.", s.ActiveTickers)
+	gauge("realtime_fds_in_use", "Number of file descriptors held by the engine.", s.FDsInUse)
+	gauge("realtime_events_buffer", "Length of poller events buffer.", s.EventsBuffer)
+	counter("realtime_fires_total", "Number of timer and ticker expirations delivered.", s.Fires)
+	counter("realtime_stops_total", "Number of Stop calls.", s.Stops)
+	counter("realtime_resets_total", "Number of Reset calls.", s.Resets)
+	counter("realtime_dropped_sends_total", "Number of channel sends dropped because receiver was not ready.", s.DroppedSends)
+	counter("realtime_ticker_overruns_total", "Number of ticker expirations missed.", s.TickerOverruns)
+	counter("realtime_poll_wakeups_total", "Number of poller wakeups.", s.PollWakeups)
+	counter("realtime_handler_panics_total", "Number of panics recovered from timer callbacks.", s.HandlerPanics)
+	gauge("realtime_executor_queued", "Number of callbacks waiting for pool executor worker.", s.ExecutorQueued)
+	counter("realtime_executor_saturations_total", "Number of callbacks which found pool executor queue full.", s.ExecutorSaturations)
+	counter("realtime_executor_dropped_total", "Number of callbacks dropped because pool executor queue was full.", s.ExecutorDropped)
+	locked := int64(0)
+	if s.PollerLocked {
+		locked = 1
+	}
+	gauge("realtime_poller_locked", "Whether poller runs on locked OS thread.", locked)
+	counter("realtime_saved_wakeups_total", "Number of wakeups saved by coalescing timers with slack.", s.SavedWakeups)
+	histogram("realtime_event_batch_size", "Number of events returned by single poller wakeup.", s.EventBatch, 1)
+	histogram("realtime_fire_lateness_seconds", "Delay between timer deadline and delivery.", s.FireLateness, math.Pow10(9))
+	histogram("realtime_wakeup_latency_seconds", "Delay between earliest deadline and poller wakeup.", s.WakeupLatency, math.Pow10(9))
+
+	return bw.Flush()
+}
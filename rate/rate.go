@@ -0,0 +1,362 @@
+// Package rate provides token bucket rate limiter which measures time with
+// realtime clock, so time spent in suspend refills the bucket. API mirrors
+// golang.org/x/time/rate with realtime.Time in place of time.Time.
+package rate
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/anjmao/realtime"
+)
+
+// Limit defines maximum frequency of events in events per second.
+type Limit float64
+
+// Inf is the infinite rate limit, it allows all events.
+const Inf = Limit(math.MaxFloat64)
+
+// InfDuration is the duration returned by Delay when Reservation is not OK.
+const InfDuration = time.Duration(1<<63 - 1)
+
+// Every converts minimum time interval between events to Limit.
+func Every(interval time.Duration) Limit {
+	if interval <= 0 {
+		return Inf
+	}
+	return 1 / Limit(interval.Seconds())
+}
+
+// Limiter controls how frequently events are allowed to happen. It
+// implements token bucket of size b, initially full and refilled at rate r
+// tokens per second.
+type Limiter struct {
+	mu     sync.Mutex
+	limit  Limit
+	burst  int
+	tokens float64
+	// last is the last time tokens field was updated.
+	last realtime.Time
+	// lastEvent is the latest time of a rate-limited event, past or future.
+	lastEvent realtime.Time
+	clock     realtime.Clock
+}
+
+// NewLimiter returns new Limiter that allows events up to rate r and permits
+// bursts of at most b tokens.
+func NewLimiter(r Limit, b int) *Limiter {
+	return NewLimiterWithClock(r, b, realtime.SystemClock())
+}
+
+// NewLimiterWithClock is like NewLimiter, but measures time with clock.
+func NewLimiterWithClock(r Limit, b int, clock realtime.Clock) *Limiter {
+	now := clock.Now()
+	return &Limiter{
+		limit:  r,
+		burst:  b,
+		tokens: float64(b),
+		last:   now,
+		clock:  clock,
+	}
+}
+
+// Limit returns maximum overall event rate.
+func (lim *Limiter) Limit() Limit {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+	return lim.limit
+}
+
+// Burst returns maximum burst size.
+func (lim *Limiter) Burst() int {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+	return lim.burst
+}
+
+// Tokens returns number of tokens available now.
+func (lim *Limiter) Tokens() float64 {
+	return lim.TokensAt(lim.clock.Now())
+}
+
+// TokensAt returns number of tokens available at time t.
+func (lim *Limiter) TokensAt(t realtime.Time) float64 {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+	_, tokens := lim.advance(t)
+	return tokens
+}
+
+// Allow is shorthand for AllowN(realtime.Now(), 1).
+func (lim *Limiter) Allow() bool {
+	return lim.AllowN(lim.clock.Now(), 1)
+}
+
+// AllowN reports whether n events may happen at time now.
+func (lim *Limiter) AllowN(now realtime.Time, n int) bool {
+	return lim.reserveN(now, n, 0).ok
+}
+
+// Reservation holds information about events that are permitted by Limiter
+// to happen after a delay.
+type Reservation struct {
+	ok        bool
+	lim       *Limiter
+	tokens    int
+	timeToAct realtime.Time
+	// limit at reservation time, it can change later.
+	limit Limit
+}
+
+// OK reports whether limiter can provide requested number of tokens within
+// the maximum wait time. If OK is false, Delay returns InfDuration and
+// Cancel does nothing.
+func (r *Reservation) OK() bool {
+	return r.ok
+}
+
+// Delay is shorthand for DelayFrom(realtime.Now()).
+func (r *Reservation) Delay() time.Duration {
+	return r.DelayFrom(r.lim.clock.Now())
+}
+
+// DelayFrom returns duration for which the reservation holder must wait
+// before taking the reserved action.
+func (r *Reservation) DelayFrom(now realtime.Time) time.Duration {
+	if !r.ok {
+		return InfDuration
+	}
+	delay := r.timeToAct.Sub(now)
+	if delay < 0 {
+		return 0
+	}
+	return delay
+}
+
+// Cancel is shorthand for CancelAt(realtime.Now()).
+func (r *Reservation) Cancel() {
+	r.CancelAt(r.lim.clock.Now())
+}
+
+// CancelAt indicates that the reservation holder will not perform the
+// reserved action and reverses its effect on the limiter as much as possible,
+// considering that other reservations may have already been made.
+func (r *Reservation) CancelAt(now realtime.Time) {
+	if !r.ok {
+		return
+	}
+
+	r.lim.mu.Lock()
+	defer r.lim.mu.Unlock()
+
+	if r.lim.limit == Inf || r.tokens == 0 || r.timeToAct.Before(now) {
+		return
+	}
+
+	// Tokens reserved after this one can not be restored.
+	restore := float64(r.tokens) - r.limit.tokensFromDuration(r.lim.lastEvent.Sub(r.timeToAct))
+	if restore <= 0 {
+		return
+	}
+	now, tokens := r.lim.advance(now)
+	tokens += restore
+	if burst := float64(r.lim.burst); tokens > burst {
+		tokens = burst
+	}
+	r.lim.last = now
+	r.lim.tokens = tokens
+	if r.timeToAct == r.lim.lastEvent {
+		prevEvent := r.timeToAct.Add(r.limit.durationFromTokens(float64(-r.tokens)))
+		if !prevEvent.Before(now) {
+			r.lim.lastEvent = prevEvent
+		}
+	}
+}
+
+// Reserve is shorthand for ReserveN(realtime.Now(), 1).
+func (lim *Limiter) Reserve() *Reservation {
+	return lim.ReserveN(lim.clock.Now(), 1)
+}
+
+// ReserveN returns Reservation that indicates how long the caller must wait
+// before n events happen. Reservation is not OK if n exceeds burst or, with
+// zero limit, tokens left.
+func (lim *Limiter) ReserveN(now realtime.Time, n int) *Reservation {
+	r := lim.reserveN(now, n, InfDuration)
+	return &r
+}
+
+// Wait is shorthand for WaitN(ctx, 1).
+func (lim *Limiter) Wait(ctx context.Context) error {
+	return lim.WaitN(ctx, 1)
+}
+
+// WaitN blocks until limiter permits n events to happen. It returns an error
+// if n exceeds burst or, with zero limit, tokens left, the context is
+// canceled, or the expected wait time exceeds the context deadline.
+func (lim *Limiter) WaitN(ctx context.Context, n int) error {
+	lim.mu.Lock()
+	burst := lim.burst
+	limit := lim.limit
+	lim.mu.Unlock()
+
+	if n > burst && limit != Inf {
+		return fmt.Errorf("rate: Wait(n=%d) exceeds limiter's burst %d", n, burst)
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	now := lim.clock.Now()
+	waitLimit := InfDuration
+	if deadline, ok := ctx.Deadline(); ok {
+		waitLimit = time.Until(deadline)
+	}
+	r := lim.reserveN(now, n, waitLimit)
+	if !r.ok {
+		if limit <= 0 {
+			return fmt.Errorf("rate: Wait(n=%d) exceeds tokens left with zero limit", n)
+		}
+		return fmt.Errorf("rate: Wait(n=%d) would exceed context deadline", n)
+	}
+	delay := r.DelayFrom(now)
+	if delay == 0 {
+		return nil
+	}
+	t := lim.clock.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.Chan():
+		return nil
+	case <-ctx.Done():
+		r.Cancel()
+		return ctx.Err()
+	}
+}
+
+// SetLimit is shorthand for SetLimitAt(realtime.Now(), newLimit).
+func (lim *Limiter) SetLimit(newLimit Limit) {
+	lim.SetLimitAt(lim.clock.Now(), newLimit)
+}
+
+// SetLimitAt sets new Limit for the limiter. Reservations which were made
+// before the call keep their delay.
+func (lim *Limiter) SetLimitAt(now realtime.Time, newLimit Limit) {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+
+	now, tokens := lim.advance(now)
+	lim.last = now
+	lim.tokens = tokens
+	lim.limit = newLimit
+}
+
+// SetBurst is shorthand for SetBurstAt(realtime.Now(), newBurst).
+func (lim *Limiter) SetBurst(newBurst int) {
+	lim.SetBurstAt(lim.clock.Now(), newBurst)
+}
+
+// SetBurstAt sets new burst size for the limiter.
+func (lim *Limiter) SetBurstAt(now realtime.Time, newBurst int) {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+
+	now, tokens := lim.advance(now)
+	lim.last = now
+	lim.tokens = tokens
+	lim.burst = newBurst
+}
+
+// reserveN reserves n tokens at time now if they become available within
+// maxFutureReserve.
+func (lim *Limiter) reserveN(now realtime.Time, n int, maxFutureReserve time.Duration) Reservation {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+
+	if lim.limit == Inf {
+		return Reservation{
+			ok:        true,
+			lim:       lim,
+			tokens:    n,
+			timeToAct: now,
+		}
+	}
+
+	now, tokens := lim.advance(now)
+	if lim.limit <= 0 {
+		// Tokens are never refilled, only what is left of burst is allowed.
+		r := Reservation{
+			ok:        float64(n) <= tokens,
+			lim:       lim,
+			timeToAct: now,
+		}
+		if r.ok {
+			r.tokens = n
+			lim.last = now
+			lim.tokens = tokens - float64(n)
+		}
+		return r
+	}
+	tokens -= float64(n)
+
+	var waitDuration time.Duration
+	if tokens < 0 {
+		waitDuration = lim.limit.durationFromTokens(-tokens)
+	}
+	// Tokens which are not refilled in InfDuration are never available.
+	ok := n <= lim.burst && waitDuration <= maxFutureReserve && waitDuration < InfDuration
+
+	r := Reservation{
+		ok:    ok,
+		lim:   lim,
+		limit: lim.limit,
+	}
+	if ok {
+		r.tokens = n
+		r.timeToAct = now.Add(waitDuration)
+
+		lim.last = now
+		lim.tokens = tokens
+		lim.lastEvent = r.timeToAct
+	}
+	return r
+}
+
+// advance calculates tokens available at time now. It must be called with
+// mu held.
+func (lim *Limiter) advance(now realtime.Time) (realtime.Time, float64) {
+	last := lim.last
+	if now.Before(last) {
+		last = now
+	}
+
+	elapsed := now.Sub(last)
+	tokens := lim.tokens + lim.limit.tokensFromDuration(elapsed)
+	if burst := float64(lim.burst); tokens > burst {
+		tokens = burst
+	}
+	return now, tokens
+}
+
+func (limit Limit) durationFromTokens(tokens float64) time.Duration {
+	if limit <= 0 {
+		return InfDuration
+	}
+	d := float64(time.Second) * tokens / float64(limit)
+	if d >= float64(InfDuration) {
+		return InfDuration
+	}
+	return time.Duration(d)
+}
+
+func (limit Limit) tokensFromDuration(d time.Duration) float64 {
+	if limit <= 0 {
+		return 0
+	}
+	return d.Seconds() * float64(limit)
+}
@@ -0,0 +1,235 @@
+package rate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/anjmao/realtime"
+	"github.com/anjmao/realtime/internal/clocktest"
+)
+
+func TestEvery(t *testing.T) {
+	if l := Every(100 * time.Millisecond); l != 10 {
+		t.Fatalf("expected limit 10, got %v", l)
+	}
+	if l := Every(0); l != Inf {
+		t.Fatalf("expected Inf limit, got %v", l)
+	}
+}
+
+func TestAllow(t *testing.T) {
+	clock := clocktest.New()
+	lim := NewLimiterWithClock(10, 2, clock)
+
+	if !lim.Allow() || !lim.Allow() {
+		t.Fatal("expected burst to be allowed")
+	}
+	if lim.Allow() {
+		t.Fatal("expected event over burst to be denied")
+	}
+	clock.Advance(100 * time.Millisecond)
+	if !lim.Allow() {
+		t.Fatal("expected event to be allowed after refill")
+	}
+	if lim.Allow() {
+		t.Fatal("expected event to be denied")
+	}
+
+	// Suspend refills the bucket.
+	clock.Advance(8 * time.Hour)
+	if tokens := lim.Tokens(); tokens != 2 {
+		t.Fatalf("expected full bucket, got %v tokens", tokens)
+	}
+}
+
+func TestAllowN(t *testing.T) {
+	clock := clocktest.New()
+	lim := NewLimiterWithClock(1, 5, clock)
+	now := clock.Now()
+	if !lim.AllowN(now, 5) {
+		t.Fatal("expected 5 events to be allowed")
+	}
+	if lim.AllowN(now.Add(time.Second), 2) {
+		t.Fatal("expected 2 events to be denied")
+	}
+	if !lim.AllowN(now.Add(2*time.Second), 2) {
+		t.Fatal("expected 2 events to be allowed")
+	}
+	if lim.AllowN(now, 6) {
+		t.Fatal("expected events over burst to be denied")
+	}
+}
+
+func TestReserve(t *testing.T) {
+	clock := clocktest.New()
+	lim := NewLimiterWithClock(10, 1, clock)
+	now := clock.Now()
+
+	r := lim.ReserveN(now, 1)
+	if !r.OK() || r.DelayFrom(now) != 0 {
+		t.Fatalf("expected immediate reservation, got ok=%v delay=%s", r.OK(), r.DelayFrom(now))
+	}
+	r = lim.ReserveN(now, 1)
+	if d := r.DelayFrom(now); d != 100*time.Millisecond {
+		t.Fatalf("expected 100ms delay, got %s", d)
+	}
+	r2 := lim.ReserveN(now, 1)
+	if d := r2.DelayFrom(now); d != 200*time.Millisecond {
+		t.Fatalf("expected 200ms delay, got %s", d)
+	}
+
+	// Cancel of last reservation returns its token.
+	r2.CancelAt(now)
+	if d := lim.ReserveN(now, 1).DelayFrom(now); d != 200*time.Millisecond {
+		t.Fatalf("expected 200ms delay after cancel, got %s", d)
+	}
+
+	if r := lim.ReserveN(now, 2); r.OK() || r.Delay() != InfDuration {
+		t.Fatal("expected reservation over burst to fail")
+	}
+}
+
+func TestInf(t *testing.T) {
+	lim := NewLimiter(Inf, 0)
+	for i := 0; i < 100; i++ {
+		if !lim.Allow() {
+			t.Fatal("expected Inf limiter to allow all events")
+		}
+	}
+	if err := lim.WaitN(context.Background(), 10); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestZeroLimit(t *testing.T) {
+	clock := clocktest.New()
+	lim := NewLimiterWithClock(0, 1, clock)
+	if !lim.Allow() {
+		t.Fatal("expected burst to be allowed")
+	}
+	clock.Advance(time.Hour)
+	if lim.Allow() {
+		t.Fatal("expected zero limit to never refill")
+	}
+}
+
+func TestZeroLimitReserve(t *testing.T) {
+	clock := clocktest.New()
+	lim := NewLimiterWithClock(0, 2, clock)
+	now := clock.Now()
+	if r := lim.ReserveN(now, 2); !r.OK() || r.DelayFrom(now) != 0 {
+		t.Fatalf("expected burst to be reserved without delay, got ok %v, delay %s", r.OK(), r.DelayFrom(now))
+	}
+	if r := lim.ReserveN(now, 1); r.OK() || r.DelayFrom(now) != InfDuration {
+		t.Fatalf("expected reservation over burst to fail, got ok %v, delay %s", r.OK(), r.DelayFrom(now))
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- lim.Wait(context.Background())
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected error when zero limit has no tokens left")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Wait blocked with zero limit")
+	}
+}
+
+func TestSetLimitAndBurst(t *testing.T) {
+	clock := clocktest.New()
+	lim := NewLimiterWithClock(1, 1, clock)
+	lim.Allow()
+	lim.SetLimit(10)
+	if lim.Limit() != 10 {
+		t.Fatalf("expected limit 10, got %v", lim.Limit())
+	}
+	clock.Advance(100 * time.Millisecond)
+	if !lim.Allow() {
+		t.Fatal("expected new limit to be used for refill")
+	}
+	lim.SetBurst(3)
+	clock.Advance(time.Second)
+	if lim.Burst() != 3 || lim.Tokens() != 3 {
+		t.Fatalf("expected burst of 3 tokens, got burst %d, %v tokens", lim.Burst(), lim.Tokens())
+	}
+}
+
+func TestWait(t *testing.T) {
+	clock := clocktest.New()
+	lim := NewLimiterWithClock(10, 1, clock)
+	ctx := context.Background()
+	if err := lim.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- lim.Wait(ctx)
+	}()
+	clock.WaitTimers(1)
+	select {
+	case <-done:
+		t.Fatal("Wait returned before refill")
+	default:
+	}
+	clock.Advance(100 * time.Millisecond)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := lim.WaitN(ctx, 2); err == nil {
+		t.Fatal("expected error for n over burst")
+	}
+}
+
+func TestWaitCancel(t *testing.T) {
+	clock := clocktest.New()
+	lim := NewLimiterWithClock(1, 1, clock)
+	lim.Allow()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- lim.Wait(ctx)
+	}()
+	clock.WaitTimers(1)
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if clock.Timers() != 0 {
+		t.Fatal("expected timer to be stopped")
+	}
+	// Cancelled reservation returned its token.
+	clock.Advance(time.Second)
+	if !lim.Allow() {
+		t.Fatal("expected token to be available")
+	}
+}
+
+func TestWaitDeadline(t *testing.T) {
+	lim := NewLimiter(Every(time.Hour), 1)
+	lim.Allow()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := lim.Wait(ctx); err == nil {
+		t.Fatal("expected error when wait exceeds deadline")
+	}
+}
+
+func TestWaitSystemClock(t *testing.T) {
+	lim := NewLimiter(Every(20*time.Millisecond), 1)
+	start := realtime.Now()
+	for i := 0; i < 3; i++ {
+		if err := lim.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := realtime.Since(start); d < 40*time.Millisecond {
+		t.Fatalf("3 events took %s, expected at least 40ms", d)
+	}
+}
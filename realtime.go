@@ -1,7 +1,10 @@
 package realtime
 
 import (
+	"errors"
 	"fmt"
+	"runtime"
+	"sync/atomic"
 	"time"
 )
 
@@ -10,7 +13,7 @@ type Time struct {
 }
 
 func Now() Time {
-	return Time{ns: time.Duration(nanotime())}
+	return Time{ns: time.Duration(clockNow())}
 }
 
 func (t Time) Sub(u Time) time.Duration {
@@ -43,92 +46,150 @@ func Since(u Time) time.Duration {
 }
 
 func Sleep(d time.Duration) {
-	<-NewTimer(d).C
+	<-newTimer(d).C
 }
 
-func AfterFunc(d time.Duration, f func()) *Timer {
-	t := &Timer{}
-	t.id = startTimer(d, func() {
-		if f != nil {
-			go f()
-		}
-	})
-	return t
+func AfterFunc(d time.Duration, f func(), opts ...TimerOption) *Timer {
+	r := newTimerState(timerEvent, nil, f, opts)
+	r.start(d, 0)
+	track(r)
+	return &Timer{r: r}
 }
 
 func After(d time.Duration) <-chan Time {
-	return NewTimer(d).C
+	return newTimer(d).C
+}
+
+func NewTimer(d time.Duration, opts ...TimerOption) *Timer {
+	t := newTimer(d, opts...)
+	runtime.SetFinalizer(t, (*Timer).release)
+	return t
 }
 
-func NewTimer(d time.Duration) *Timer {
-	c := make(chan Time, 1)
+// newTimer creates timer without finalizer for internal use where channel
+// may outlive Timer.
+func newTimer(d time.Duration, opts ...TimerOption) *Timer {
+	r := newTimerState(timerEvent, newTimerChan(), nil, opts)
 	t := &Timer{
-		C: c,
+		C: r.c,
+		r: r,
 	}
-	t.startTimer(d)
+	r.start(d, 0)
+	track(r)
 	return t
 }
 
 type Timer struct {
-	C       chan Time
-	stopped bool
-	id      int
+	C chan Time
+	r *timer
 }
 
 func (t *Timer) String() string {
-	return fmt.Sprintf("timer#%d", t.id)
+	return fmt.Sprintf("timer#%d", t.r.id)
 }
 
 func (t *Timer) Stop() bool {
-	if t.stopped {
-		return true
-	}
-	stopTimer(t.id)
-	return !t.stopped
+	atomic.AddUint64(&stats.stops, 1)
+	untrack(t.r)
+	return t.r.stop()
 }
 
 func (t *Timer) Reset(d time.Duration) bool {
-	resetTimer(t.id, d)
-	return true
+	atomic.AddUint64(&stats.resets, 1)
+	return t.r.reset(d, 0)
+}
+
+// Remaining returns time left until timer expires or zero if timer has
+// expired or was stopped.
+func (t *Timer) Remaining() time.Duration {
+	return t.r.remaining()
+}
+
+// Deadline returns time when timer expires or expired after it was created
+// or last reset.
+func (t *Timer) Deadline() Time {
+	return t.r.getDeadline()
 }
 
-func (t *Timer) startTimer(d time.Duration) {
-	t.stopped = false
-	t.id = startTimer(d, func() {
-		select {
-		case t.C <- Now():
-		default:
-		}
-	})
+// Fired reports whether timer expired since it was created or last reset.
+func (t *Timer) Fired() bool {
+	return t.r.fired()
 }
 
+// release stops timer nobody references, so its event and fd are freed and
+// value which was never received is dropped.
+func (t *Timer) release() {
+	untrack(t.r)
+	t.r.stop()
+}
+
+// Tick is like NewTicker(d).C. Ticker behind the channel can not be stopped
+// or collected, use NewTicker when it has to be released.
 func Tick(d time.Duration) <-chan Time {
-	return NewTicker(d).C
+	return newTicker(d).C
+}
+
+func NewTicker(d time.Duration, opts ...TimerOption) *Ticker {
+	t := newTicker(d, opts...)
+	runtime.SetFinalizer(t, (*Ticker).release)
+	return t
 }
 
-func NewTicker(d time.Duration) *Ticker {
-	c := make(chan Time, 1)
+func newTicker(d time.Duration, opts ...TimerOption) *Ticker {
+	r := newTimerState(tickerEvent, newTimerChan(), nil, opts)
 	t := &Ticker{
-		C: c,
+		C: r.c,
+		r: r,
 	}
-	t.id = startTicker(d, func() {
-		select {
-		case t.C <- Now():
-		default:
-		}
-	})
+	r.start(d, d)
+	track(r)
 	return t
 }
 
 type Ticker struct {
-	C  chan Time
-	id int
+	C chan Time
+	r *timer
 }
 
 func (t *Ticker) Stop() {
-	stopTicker(t.id)
+	atomic.AddUint64(&stats.stops, 1)
+	untrack(t.r)
+	t.r.stop()
+}
+
+// Reset stops ticker and resets its period to d. Next tick arrives after d.
+// Underlying timer is re-armed in place. Aligned ticker stops being aligned.
+func (t *Ticker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic(errors.New("non-positive interval for Ticker.Reset"))
+	}
+	atomic.AddUint64(&stats.resets, 1)
+	t.r.reset(d, d)
+}
+
+// ResetAt re-phases ticker so that next tick arrives at first and following
+// ticks every period after it. First tick in the past fires immediately.
+func (t *Ticker) ResetAt(first Time, period time.Duration) {
+	if period <= 0 {
+		panic(errors.New("non-positive interval for Ticker.ResetAt"))
+	}
+	atomic.AddUint64(&stats.resets, 1)
+	t.r.reset(first.Sub(Now()), period)
+}
+
+// Missed returns number of ticks which were not delivered since previous
+// call. Missed ticks are counted only by FireOnceWithCount and FireAll
+// policies.
+func (t *Ticker) Missed() uint64 {
+	return t.r.takeMissed()
+}
+
+// release stops ticker nobody references.
+func (t *Ticker) release() {
+	untrack(t.r)
+	t.r.stop()
 }
 
 func (t *Ticker) String() string {
-	return fmt.Sprintf("ticker#%d", t.id)
+	return fmt.Sprintf("ticker#%d", t.r.id)
 }
@@ -116,6 +116,71 @@ func TestConcurrentTimerReset(t *testing.T) {
 	wg.Wait()
 }
 
+func TestTimerStopResult(t *testing.T) {
+	timer := NewTimer(time.Hour)
+	if !timer.Stop() {
+		t.Fatal("Stop of active timer = false, want true")
+	}
+	if timer.Stop() {
+		t.Fatal("Stop of stopped timer = true, want false")
+	}
+	if timer.Reset(time.Millisecond) {
+		t.Fatal("Reset of stopped timer = true, want false")
+	}
+	<-timer.C
+	if timer.Stop() {
+		t.Fatal("Stop of expired timer = true, want false")
+	}
+	if timer.Reset(time.Hour) {
+		t.Fatal("Reset of expired timer = true, want false")
+	}
+	if !timer.Reset(time.Hour) {
+		t.Fatal("Reset of active timer = false, want true")
+	}
+	timer.Stop()
+
+	var called bool
+	f := AfterFunc(time.Hour, func() { called = true })
+	if !f.Stop() {
+		t.Fatal("Stop of pending AfterFunc = false, want true")
+	}
+	if called {
+		t.Fatal("AfterFunc callback should not be called")
+	}
+}
+
+func TestTimerState(t *testing.T) {
+	start := Now()
+	timer := NewTimer(time.Hour)
+	if timer.Fired() {
+		t.Fatal("new timer should not be fired")
+	}
+	if d := timer.Remaining(); d <= 59*time.Minute || d > time.Hour {
+		t.Fatalf("expected remaining time close to hour, got %s", d)
+	}
+	if d := timer.Deadline().Sub(start); d < time.Hour || d > time.Hour+time.Second {
+		t.Fatalf("expected deadline in an hour, got %s", d)
+	}
+
+	timer.Reset(time.Millisecond)
+	<-timer.C
+	if !timer.Fired() {
+		t.Fatal("expected timer to be fired")
+	}
+	if d := timer.Remaining(); d != 0 {
+		t.Fatalf("expected no remaining time, got %s", d)
+	}
+	if timer.Deadline().After(Now()) {
+		t.Fatalf("expected deadline in the past, got %s", timer.Deadline())
+	}
+
+	timer.Reset(time.Hour)
+	timer.Stop()
+	if timer.Fired() || timer.Remaining() != 0 {
+		t.Fatal("stopped timer should not be fired and have no remaining time")
+	}
+}
+
 func TestTicker(t *testing.T) {
 	const Count = 10
 	Delta := 100 * time.Millisecond
@@ -142,6 +207,50 @@ func TestTicker(t *testing.T) {
 	}
 }
 
+func TestTickerReset(t *testing.T) {
+	ticker := NewTicker(time.Hour)
+	defer ticker.Stop()
+	fds := Stats().FDsInUse
+
+	const delta = 20 * time.Millisecond
+	ticker.Reset(delta)
+	if actual := Stats().FDsInUse; actual != fds {
+		t.Fatalf("expected Reset to reuse fd, %d fds before, %d after", fds, actual)
+	}
+
+	t0 := Now()
+	for i := 0; i < 3; i++ {
+		<-ticker.C
+	}
+	if dt := Since(t0); dt < 3*delta || dt > time.Second {
+		t.Fatalf("3 ticks of %s took %s", delta, dt)
+	}
+
+	for _, info := range Timers() {
+		if info.ID == ticker.r.id && info.Period != delta {
+			t.Fatalf("expected period %s, got %s", delta, info.Period)
+		}
+	}
+}
+
+func TestTickerResetAt(t *testing.T) {
+	ticker := NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	first := Now().Add(50 * time.Millisecond)
+	const period = 10 * time.Millisecond
+	ticker.ResetAt(first, period)
+
+	tick := <-ticker.C
+	if tick.Before(first) {
+		t.Fatalf("first tick %s before %s", tick, first)
+	}
+	tick = <-ticker.C
+	if tick.Before(first.Add(period)) {
+		t.Fatalf("second tick %s before %s", tick, first.Add(period))
+	}
+}
+
 func TestTimersTickersNoOverrides(t *testing.T) {
 	var wg sync.WaitGroup
 	wg.Add(4)
@@ -0,0 +1,42 @@
+// Package realtimetest provides test helpers for code using realtime timers.
+// It is separate from realtime so programs do not link testing package.
+package realtimetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/anjmao/realtime"
+)
+
+// VerifyNone reports test error for every tracked leaked timer or ticker.
+// Pending timers are given a short grace period to fire and be drained.
+//
+//	func TestMain(m *testing.M) {
+//		realtime.Configure(realtime.WithTracking(true))
+//		os.Exit(m.Run())
+//	}
+//
+//	func TestSomething(t *testing.T) {
+//		defer realtimetest.VerifyNone(t)
+//		...
+//	}
+func VerifyNone(t testing.TB) {
+	t.Helper()
+
+	const (
+		maxWait = time.Second
+		step    = 10 * time.Millisecond
+	)
+	var leaks []realtime.Leak
+	for waited := time.Duration(0); ; waited += step {
+		leaks = realtime.Leaks(0)
+		if len(leaks) == 0 || waited >= maxWait {
+			break
+		}
+		time.Sleep(step)
+	}
+	for _, l := range leaks {
+		t.Errorf("found leaked %s", l)
+	}
+}
@@ -0,0 +1,44 @@
+package realtimetest
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/anjmao/realtime"
+)
+
+type fakeTB struct {
+	testing.TB
+	errors []string
+}
+
+func (t *fakeTB) Helper() {}
+
+func (t *fakeTB) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestVerifyNone(t *testing.T) {
+	realtime.Configure(realtime.WithTracking(true))
+	defer realtime.Configure(realtime.WithTracking(false))
+
+	ticker := realtime.NewTicker(time.Hour)
+	timer := realtime.NewTimer(time.Millisecond)
+	<-realtime.After(time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+
+	tb := &fakeTB{}
+	VerifyNone(tb)
+	if len(tb.errors) != 2 {
+		t.Fatalf("expected 2 errors, got %v", tb.errors)
+	}
+
+	ticker.Stop()
+	<-timer.C
+	tb = &fakeTB{}
+	VerifyNone(tb)
+	if len(tb.errors) != 0 {
+		t.Fatalf("expected no errors, got %v", tb.errors)
+	}
+}
@@ -0,0 +1,215 @@
+// Package sdwatchdog sends systemd service notifications and keeps systemd
+// watchdog (WatchdogSec) satisfied. Pings are sent by realtime ticker, so
+// they resume right after suspend.
+package sdwatchdog
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anjmao/realtime"
+)
+
+// Notification states understood by systemd.
+const (
+	Ready     = "READY=1"
+	Stopping  = "STOPPING=1"
+	Reloading = "RELOADING=1"
+	Ping      = "WATCHDOG=1"
+)
+
+// ErrNoSocket is returned by Notify when NOTIFY_SOCKET is not set, i.e.
+// process was not started by systemd with notify support.
+var ErrNoSocket = errors.New("sdwatchdog: NOTIFY_SOCKET is not set")
+
+// Status returns state which sets free-form service status.
+func Status(s string) string {
+	return "STATUS=" + s
+}
+
+// Notify sends states to systemd.
+func Notify(states ...string) error {
+	conn, err := dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return send(conn, states)
+}
+
+// Interval returns watchdog interval from WATCHDOG_USEC or zero if watchdog
+// is not enabled for this process.
+func Interval() (time.Duration, error) {
+	usec := os.Getenv("WATCHDOG_USEC")
+	if usec == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseUint(usec, 10, 63)
+	if err != nil || n == 0 {
+		return 0, fmt.Errorf("sdwatchdog: bad WATCHDOG_USEC %q", usec)
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" {
+		p, err := strconv.Atoi(pid)
+		if err != nil {
+			return 0, fmt.Errorf("sdwatchdog: bad WATCHDOG_PID %q", pid)
+		}
+		if p != os.Getpid() {
+			return 0, nil
+		}
+	}
+	return time.Duration(n) * time.Microsecond, nil
+}
+
+func dial() (*net.UnixConn, error) {
+	name := os.Getenv("NOTIFY_SOCKET")
+	if name == "" {
+		return nil, ErrNoSocket
+	}
+	if strings.HasPrefix(name, "@") {
+		// Abstract namespace socket.
+		name = "\x00" + name[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: name, Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("sdwatchdog: %w", err)
+	}
+	return conn, nil
+}
+
+func send(conn *net.UnixConn, states []string) error {
+	if _, err := conn.Write([]byte(strings.Join(states, "\n"))); err != nil {
+		return fmt.Errorf("sdwatchdog: %w", err)
+	}
+	return nil
+}
+
+// Option configures Watchdog.
+type Option func(*Watchdog)
+
+// WithHealthCheck sets function called before every ping. Pings are not
+// sent while it returns error, so systemd restarts unhealthy service once
+// watchdog interval passes.
+func WithHealthCheck(f func() error) Option {
+	return func(w *Watchdog) {
+		w.health = f
+	}
+}
+
+// Watchdog pings systemd watchdog at half of its interval.
+type Watchdog struct {
+	mu       sync.Mutex
+	conn     *net.UnixConn
+	interval time.Duration
+	health   func() error
+	// err is the last health check or ping error.
+	err    error
+	ticker *realtime.Ticker
+	done   chan struct{}
+	wg     sync.WaitGroup
+	stop   sync.Once
+}
+
+// Start connects to NOTIFY_SOCKET and starts pinging if watchdog is enabled
+// for this process. It returns ErrNoSocket when not started by systemd.
+func Start(opts ...Option) (*Watchdog, error) {
+	interval, err := Interval()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	w := &Watchdog{
+		conn:     conn,
+		interval: interval,
+		done:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if interval > 0 {
+		w.ticker = realtime.NewTicker(interval / 2)
+		w.wg.Add(1)
+		go w.run()
+	}
+	return w, nil
+}
+
+// Interval returns watchdog interval, zero if watchdog is not enabled.
+func (w *Watchdog) Interval() time.Duration {
+	return w.interval
+}
+
+// Err returns error of the last health check or ping.
+func (w *Watchdog) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// Notify sends states to systemd.
+func (w *Watchdog) Notify(states ...string) error {
+	return send(w.conn, states)
+}
+
+// Ready tells systemd that service startup is finished.
+func (w *Watchdog) Ready() error {
+	return w.Notify(Ready)
+}
+
+// Stopping tells systemd that service is shutting down.
+func (w *Watchdog) Stopping() error {
+	return w.Notify(Stopping)
+}
+
+// Status sets free-form service status.
+func (w *Watchdog) Status(s string) error {
+	return w.Notify(Status(s))
+}
+
+// Stop stops pinging and closes socket.
+func (w *Watchdog) Stop() error {
+	err := errors.New("sdwatchdog: already stopped")
+	w.stop.Do(func() {
+		if w.ticker != nil {
+			w.ticker.Stop()
+			close(w.done)
+			w.wg.Wait()
+		}
+		err = w.conn.Close()
+	})
+	return err
+}
+
+func (w *Watchdog) run() {
+	defer w.wg.Done()
+	w.ping()
+	for {
+		select {
+		case <-w.ticker.C:
+			w.ping()
+		case <-w.done:
+			return
+		}
+	}
+}
+
+func (w *Watchdog) ping() {
+	var err error
+	if w.health != nil {
+		err = w.health()
+	}
+	if err == nil {
+		err = send(w.conn, []string{Ping})
+	}
+	w.mu.Lock()
+	w.err = err
+	w.mu.Unlock()
+}
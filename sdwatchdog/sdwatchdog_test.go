@@ -0,0 +1,200 @@
+package sdwatchdog
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeSystemd listens on unixgram socket set in NOTIFY_SOCKET.
+type fakeSystemd struct {
+	conn    *net.UnixConn
+	dir     string
+	restore func()
+}
+
+func newFakeSystemd(t *testing.T, env map[string]string) *fakeSystemd {
+	dir, err := ioutil.TempDir("", "sdwatchdog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	env["NOTIFY_SOCKET"] = path
+	return &fakeSystemd{conn: conn, dir: dir, restore: setenv(env)}
+}
+
+func (s *fakeSystemd) close() {
+	s.restore()
+	s.conn.Close()
+	os.RemoveAll(s.dir)
+}
+
+// setenv sets environment variables until returned function is called.
+func setenv(env map[string]string) func() {
+	prev := map[string]*string{}
+	for _, k := range []string{"NOTIFY_SOCKET", "WATCHDOG_USEC", "WATCHDOG_PID"} {
+		if v, ok := os.LookupEnv(k); ok {
+			prev[k] = &v
+		} else {
+			prev[k] = nil
+		}
+		os.Unsetenv(k)
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+	}
+	return func() {
+		for k, v := range prev {
+			if v == nil {
+				os.Unsetenv(k)
+			} else {
+				os.Setenv(k, *v)
+			}
+		}
+	}
+}
+
+// receive returns next notification or empty string on timeout.
+func (s *fakeSystemd) receive(t *testing.T, timeout time.Duration) string {
+	t.Helper()
+	buf := make([]byte, 1024)
+	s.conn.SetReadDeadline(time.Now().Add(timeout))
+	n, err := s.conn.Read(buf)
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return ""
+		}
+		t.Fatal(err)
+	}
+	return string(buf[:n])
+}
+
+func (s *fakeSystemd) expect(t *testing.T, want string) {
+	t.Helper()
+	if got := s.receive(t, time.Second); got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestInterval(t *testing.T) {
+	pid := strconv.Itoa(os.Getpid())
+	tests := []struct {
+		env  map[string]string
+		want time.Duration
+		err  bool
+	}{
+		{map[string]string{}, 0, false},
+		{map[string]string{"WATCHDOG_USEC": "30000000"}, 30 * time.Second, false},
+		{map[string]string{"WATCHDOG_USEC": "30000000", "WATCHDOG_PID": pid}, 30 * time.Second, false},
+		{map[string]string{"WATCHDOG_USEC": "30000000", "WATCHDOG_PID": "1"}, 0, false},
+		{map[string]string{"WATCHDOG_USEC": "soon"}, 0, true},
+		{map[string]string{"WATCHDOG_USEC": "0"}, 0, true},
+		{map[string]string{"WATCHDOG_USEC": "100", "WATCHDOG_PID": "me"}, 0, true},
+	}
+	for _, tt := range tests {
+		restore := setenv(tt.env)
+		d, err := Interval()
+		restore()
+		if (err != nil) != tt.err || d != tt.want {
+			t.Errorf("%v: expected %s, error %v, got %s, %v", tt.env, tt.want, tt.err, d, err)
+		}
+	}
+}
+
+func TestNotify(t *testing.T) {
+	restore := setenv(map[string]string{})
+	if err := Notify(Ready); err != ErrNoSocket {
+		t.Fatalf("expected %v, got %v", ErrNoSocket, err)
+	}
+	restore()
+
+	s := newFakeSystemd(t, map[string]string{})
+	defer s.close()
+	if err := Notify(Ready, Status("serving")); err != nil {
+		t.Fatal(err)
+	}
+	s.expect(t, "READY=1\nSTATUS=serving")
+
+	w, err := Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	if w.Interval() != 0 {
+		t.Fatalf("expected disabled watchdog, got interval %s", w.Interval())
+	}
+	w.Ready()
+	s.expect(t, Ready)
+	w.Status("draining")
+	s.expect(t, "STATUS=draining")
+	w.Stopping()
+	s.expect(t, Stopping)
+	if got := s.receive(t, 50*time.Millisecond); got != "" {
+		t.Fatalf("expected no pings from disabled watchdog, got %q", got)
+	}
+}
+
+func TestWatchdogPings(t *testing.T) {
+	s := newFakeSystemd(t, map[string]string{
+		"WATCHDOG_USEC": "40000",
+		"WATCHDOG_PID":  strconv.Itoa(os.Getpid()),
+	})
+	defer s.close()
+
+	var healthy int32 = 1
+	errUnhealthy := errors.New("unhealthy")
+	w, err := Start(WithHealthCheck(func() error {
+		if atomic.LoadInt32(&healthy) == 0 {
+			return errUnhealthy
+		}
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	if w.Interval() != 40*time.Millisecond {
+		t.Fatalf("expected 40ms interval, got %s", w.Interval())
+	}
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		s.expect(t, Ping)
+	}
+	// The first ping is sent immediately, then every 20ms.
+	if d := time.Since(start); d < 80*time.Millisecond {
+		t.Fatalf("5 pings took only %s", d)
+	}
+
+	atomic.StoreInt32(&healthy, 0)
+	// Drain ping which may have been sent before health changed.
+	s.receive(t, 30*time.Millisecond)
+	if got := s.receive(t, 100*time.Millisecond); got != "" {
+		t.Fatalf("expected no pings while unhealthy, got %q", got)
+	}
+	if err := w.Err(); err != errUnhealthy {
+		t.Fatalf("expected %v, got %v", errUnhealthy, err)
+	}
+
+	atomic.StoreInt32(&healthy, 1)
+	s.expect(t, Ping)
+
+	if err := w.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Stop(); err == nil {
+		t.Fatal("expected error from second Stop")
+	}
+}
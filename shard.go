@@ -0,0 +1,68 @@
+package realtime
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// WithShards sets number of pollers timers are spread across. Every shard
+// has its own timer map, lock and poll goroutine, so registration of timers
+// does not contend on a single lock. Shard count is fixed when the first
+// timer is created, Configure panics if WithShards requests a different count
+// afterwards. REALTIME_SHARDS environment variable sets it at startup.
+// Default is one shard.
+func WithShards(n int) Option {
+	return func(c *config) {
+		if n < 1 {
+			n = 1
+		}
+		if engineStarted() && n != c.shards {
+			panic(fmt.Errorf("realtime: shard count can not be changed from %d to %d after first timer was created", c.shards, n))
+		}
+		c.shards = n
+	}
+}
+
+func shardsFromEnv() int {
+	n, err := strconv.Atoi(os.Getenv("REALTIME_SHARDS"))
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
+
+// shards routes events to pollers by event id. Ids are sequential, so events
+// are spread evenly.
+type shards []poller
+
+func (s shards) shard(id uint64) poller {
+	return s[id%uint64(len(s))]
+}
+
+func (s shards) registerEvent(id uint64, kind eventKind, d, period time.Duration, handler timerHandler) error {
+	return s.shard(id).registerEvent(id, kind, d, period, handler)
+}
+
+func (s shards) deleteEvent(id uint64) error {
+	return s.shard(id).deleteEvent(id)
+}
+
+func (s shards) remaining(id uint64) (time.Duration, error) {
+	return s.shard(id).remaining(id)
+}
+
+func (s shards) close() {
+	for _, p := range s {
+		p.close()
+	}
+}
+
+func (s shards) timers() []TimerInfo {
+	var infos []TimerInfo
+	for _, p := range s {
+		infos = append(infos, p.timers()...)
+	}
+	return infos
+}
@@ -0,0 +1,104 @@
+package realtime
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newShards(n int) shards {
+	s := make(shards, n)
+	for i := range s {
+		s[i] = newPoller()
+	}
+	return s
+}
+
+func TestShards(t *testing.T) {
+	s := newShards(3)
+	defer s.close()
+
+	var wg sync.WaitGroup
+	var ids []uint64
+	for i := 0; i < 6; i++ {
+		id := nextEventID()
+		ids = append(ids, id)
+		wg.Add(1)
+		if err := s.registerEvent(id, timerEvent, 10*time.Millisecond, 0, func(uint64, uint64) { wg.Done() }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stopped := nextEventID()
+	if err := s.registerEvent(stopped, timerEvent, time.Hour, 0, func(uint64, uint64) {}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, p := range s {
+		if n := len(p.timers()); n < 2 {
+			t.Fatalf("expected shard %d to have at least 2 events, got %d", i, n)
+		}
+	}
+	if n := len(s.timers()); n != 7 {
+		t.Fatalf("expected 7 events, got %d", n)
+	}
+	if d, err := s.remaining(stopped); err != nil || d < 59*time.Minute {
+		t.Fatalf("expected about an hour remaining, got %s, %v", d, err)
+	}
+
+	wg.Wait()
+	if err := s.deleteEvent(stopped); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return len(s.timers()) == 0 })
+}
+
+func TestPollerClose(t *testing.T) {
+	fds := Stats().FDsInUse
+	s := newShards(3)
+	for i := 0; i < 6; i++ {
+		if err := s.registerEvent(nextEventID(), tickerEvent, time.Hour, time.Hour, func(uint64, uint64) {}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.close()
+	if actual := Stats().FDsInUse; actual != fds {
+		t.Fatalf("expected %d fds in use after close, got %d", fds, actual)
+	}
+}
+
+func TestWithShardsAfterStart(t *testing.T) {
+	startEngine()
+	before := getConfig().shards
+	Configure(WithShards(before))
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected panic when shard count is changed after start")
+			}
+		}()
+		Configure(WithShards(before + 1))
+	}()
+	if n := getConfig().shards; n != before {
+		t.Fatalf("expected shard count to stay %d, got %d", before, n)
+	}
+}
+
+func BenchmarkShards(b *testing.B) {
+	prev := getEngine()
+	defer setEngine(prev)
+	for _, n := range []int{1, 2, 4, 8, 16, 32, 64} {
+		s := newShards(n)
+		setEngine(s)
+		b.Run(fmt.Sprintf("shards=%d", n), func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					NewTimer(time.Hour).Stop()
+				}
+			})
+		})
+		setEngine(prev)
+		s.close()
+	}
+}
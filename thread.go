@@ -0,0 +1,118 @@
+package realtime
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PollerThread configures OS thread which runs the poller.
+type PollerThread struct {
+	// Priority is SCHED_FIFO priority from 1 to 99. Zero keeps default
+	// scheduling policy. Linux only, requires CAP_SYS_NICE.
+	Priority int
+	// CPUs the thread is allowed to run on. Empty keeps current affinity.
+	// Linux only.
+	CPUs []int
+	// TimerSlack of the thread, zero keeps default slack. Linux only.
+	TimerSlack time.Duration
+}
+
+// LockPollerThread locks poller goroutines of all shards to their OS threads,
+// so they are not delayed by other goroutines, and applies thread settings.
+// Pollers stay locked if some setting can not be applied, returned error
+// describes the first failure, e.g. EPERM for SCHED_FIFO without
+// CAP_SYS_NICE. Stats report poller as locked only when all settings were
+// applied.
+func LockPollerThread(t PollerThread) error {
+	err := onPollerThreads(func(p poller) error {
+		lockedThreads.mu.Lock()
+		if _, ok := lockedThreads.restore[p]; !ok {
+			// Settings of the thread before it was locked first.
+			runtime.LockOSThread()
+			lockedThreads.restore[p] = saveThread()
+		}
+		lockedThreads.mu.Unlock()
+		return configureThread(t)
+	})
+	if err == nil {
+		atomic.StoreInt64(&stats.pollerLocked, 1)
+	}
+	return err
+}
+
+// UnlockPollerThread restores settings which poller threads had before
+// LockPollerThread and unlocks pollers from their OS threads.
+func UnlockPollerThread() error {
+	err := onPollerThreads(func(p poller) error {
+		lockedThreads.mu.Lock()
+		restore, ok := lockedThreads.restore[p]
+		delete(lockedThreads.restore, p)
+		lockedThreads.mu.Unlock()
+		if !ok {
+			return nil
+		}
+		err := restore()
+		runtime.UnlockOSThread()
+		return err
+	})
+	atomic.StoreInt64(&stats.pollerLocked, 0)
+	return err
+}
+
+var lockedThreads = struct {
+	mu      sync.Mutex
+	restore map[poller]func() error
+}{restore: map[poller]func() error{}}
+
+// onPollerThreads calls f on poller goroutine of every shard and returns the
+// first error.
+func onPollerThreads(f func(p poller) error) error {
+	var errs []chan error
+	eachPoller(func(p poller) {
+		done := make(chan error, 1)
+		errs = append(errs, done)
+		// Handlers are called by poller goroutine.
+		err := p.registerEvent(nextEventID(), timerEvent, 0, 0, func(uint64, uint64) {
+			done <- f(p)
+		})
+		if err != nil {
+			done <- err
+		}
+	})
+	var first error
+	for _, done := range errs {
+		if err := <-done; err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// threadErrors collects failed thread settings.
+type threadErrors struct {
+	first error
+}
+
+func (e *threadErrors) add(setting string, err error) {
+	logger().Log(LevelWarn, "poller thread setting failed", "setting", setting, "err", err)
+	if e.first == nil {
+		e.first = &ThreadSettingError{Setting: setting, Err: err}
+	}
+}
+
+// ThreadSettingError is returned by LockPollerThread when thread setting can
+// not be applied.
+type ThreadSettingError struct {
+	Setting string
+	Err     error
+}
+
+func (e *ThreadSettingError) Error() string {
+	return "realtime: poller thread " + e.Setting + ": " + e.Err.Error()
+}
+
+func (e *ThreadSettingError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,23 @@
+package realtime
+
+import "errors"
+
+var errThreadNotSupported = errors.New("not supported on darwin")
+
+func configureThread(t PollerThread) error {
+	var errs threadErrors
+	if t.TimerSlack > 0 {
+		errs.add("timer slack", errThreadNotSupported)
+	}
+	if len(t.CPUs) > 0 {
+		errs.add("cpu affinity", errThreadNotSupported)
+	}
+	if t.Priority > 0 {
+		errs.add("SCHED_FIFO", errThreadNotSupported)
+	}
+	return errs.first
+}
+
+func saveThread() func() error {
+	return func() error { return nil }
+}
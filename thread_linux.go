@@ -0,0 +1,65 @@
+package realtime
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const schedFIFO = 1
+
+func configureThread(t PollerThread) error {
+	var errs threadErrors
+	if t.TimerSlack > 0 {
+		if err := unix.Prctl(unix.PR_SET_TIMERSLACK, uintptr(t.TimerSlack), 0, 0, 0); err != nil {
+			errs.add("timer slack", err)
+		}
+	}
+	if len(t.CPUs) > 0 {
+		var set unix.CPUSet
+		for _, cpu := range t.CPUs {
+			set.Set(cpu)
+		}
+		if err := unix.SchedSetaffinity(0, &set); err != nil {
+			errs.add("cpu affinity", err)
+		}
+	}
+	if t.Priority > 0 {
+		param := struct{ priority int32 }{int32(t.Priority)}
+		_, _, errno := unix.RawSyscall(unix.SYS_SCHED_SETSCHEDULER, 0, schedFIFO, uintptr(unsafe.Pointer(&param)))
+		if errno != 0 {
+			errs.add("SCHED_FIFO", errno)
+		}
+	}
+	return errs.first
+}
+
+// saveThread returns function which restores current settings of the thread.
+func saveThread() func() error {
+	slack, slackErr := unix.PrctlRetInt(unix.PR_GET_TIMERSLACK, 0, 0, 0, 0)
+	var set unix.CPUSet
+	setErr := unix.SchedGetaffinity(0, &set)
+	policy, _, policyErrno := unix.RawSyscall(unix.SYS_SCHED_GETSCHEDULER, 0, 0, 0)
+	var param struct{ priority int32 }
+	_, _, paramErrno := unix.RawSyscall(unix.SYS_SCHED_GETPARAM, 0, uintptr(unsafe.Pointer(&param)), 0)
+	return func() error {
+		var errs threadErrors
+		if slackErr == nil {
+			if err := unix.Prctl(unix.PR_SET_TIMERSLACK, uintptr(slack), 0, 0, 0); err != nil {
+				errs.add("timer slack", err)
+			}
+		}
+		if setErr == nil {
+			if err := unix.SchedSetaffinity(0, &set); err != nil {
+				errs.add("cpu affinity", err)
+			}
+		}
+		if policyErrno == 0 && paramErrno == 0 {
+			_, _, errno := unix.RawSyscall(unix.SYS_SCHED_SETSCHEDULER, 0, policy, uintptr(unsafe.Pointer(&param)))
+			if errno != 0 {
+				errs.add("scheduling policy", errno)
+			}
+		}
+		return errs.first
+	}
+}
@@ -0,0 +1,91 @@
+package realtime
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestLockPollerThread(t *testing.T) {
+	var set unix.CPUSet
+	if err := unix.SchedGetaffinity(0, &set); err != nil {
+		t.Fatal(err)
+	}
+	var cpus []int
+	for cpu := 0; cpu < len(set)*64; cpu++ {
+		if set.IsSet(cpu) {
+			cpus = append(cpus, cpu)
+		}
+	}
+
+	slack, err := unix.PrctlRetInt(unix.PR_GET_TIMERSLACK, 0, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = LockPollerThread(PollerThread{
+		CPUs:       cpus,
+		TimerSlack: time.Microsecond,
+	})
+	defer UnlockPollerThread()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Stats().PollerLocked {
+		t.Fatal("expected poller to be locked")
+	}
+
+	err = LockPollerThread(PollerThread{Priority: 1})
+	var settingErr *ThreadSettingError
+	if err != nil && (!errors.As(err, &settingErr) || !errors.Is(err, unix.EPERM)) {
+		t.Fatalf("expected nil or EPERM error, got %v", err)
+	}
+
+	// Poller still works.
+	before := Stats().WakeupLatency.Count
+	<-After(time.Millisecond)
+	if Stats().WakeupLatency.Count == before {
+		t.Fatal("expected wakeup latency to be recorded")
+	}
+
+	// Poller thread gets back its settings.
+	if err := UnlockPollerThread(); err != nil {
+		t.Fatal(err)
+	}
+	if Stats().PollerLocked {
+		t.Fatal("expected poller to be unlocked")
+	}
+	type settings struct {
+		slack  int
+		policy uintptr
+	}
+	restored := make(chan settings, 1)
+	eachPoller(func(p poller) {
+		p.registerEvent(nextEventID(), timerEvent, 0, 0, func(uint64, uint64) {
+			var s settings
+			s.slack, _ = unix.PrctlRetInt(unix.PR_GET_TIMERSLACK, 0, 0, 0, 0)
+			s.policy, _, _ = unix.RawSyscall(unix.SYS_SCHED_GETSCHEDULER, 0, 0, 0)
+			select {
+			case restored <- s:
+			default:
+			}
+		})
+	})
+	if s := <-restored; s.slack != slack || s.policy != 0 {
+		t.Fatalf("expected timer slack %d and default policy, got %+v", slack, s)
+	}
+}
+
+func TestLockPollerThreadInvalidCPU(t *testing.T) {
+	err := LockPollerThread(PollerThread{CPUs: []int{1023}})
+	defer UnlockPollerThread()
+	var settingErr *ThreadSettingError
+	if !errors.As(err, &settingErr) || settingErr.Setting != "cpu affinity" {
+		t.Fatalf("expected cpu affinity error, got %v", err)
+	}
+	if Stats().PollerLocked {
+		t.Fatal("expected poller not to be reported locked after failure")
+	}
+}
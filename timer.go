@@ -0,0 +1,375 @@
+package realtime
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WithModernTimers enables Go 1.23 timer channel semantics for timers and
+// tickers created afterwards:
+//
+// Timer and Ticker channels are unbuffered and Stop or Reset guarantee that
+// no value prepared before the call is received after it returns, so there
+// is no need to drain C before Reset.
+//
+// Stop and Reset of Timer return true if the timer was stopped before its
+// value was received, the same as in Go 1.23.
+//
+// Timers and tickers which are no longer referenced are stopped by a
+// finalizer in every mode, their fds are closed and values nobody received
+// are dropped. Channel alone does not keep Timer or Ticker alive, keep a
+// reference to it while receiving from C.
+func WithModernTimers(enabled bool) Option {
+	return func(c *config) {
+		c.modernTimers = enabled
+	}
+}
+
+// timer is state shared by Timer or Ticker and its engine handler. Handler
+// and timer never reference Timer or Ticker, so they can be collected by GC
+// while armed.
+type timer struct {
+	mu     sync.Mutex
+	id     uint64
+	kind   eventKind
+	c      chan Time
+	f      func()
+	modern bool
+	period time.Duration
+	seq    uint64 // incremented on every arm and stop, stale fires are ignored
+	state  timerState
+	// deadline of last arm.
+	deadline Time
+	send     *pendingSend
+	// jitter is fraction of delay or period by which expirations are
+	// randomly moved.
+	jitter float64
+	// slack of one shot timer, see WithSlack.
+	slack   time.Duration
+	precise bool
+	spinner *spinner
+	// next returns nominal delay until next tick of ticker which is re-armed
+	// after every tick.
+	next func() time.Duration
+	// unwatch stops re-aligning wall aligned ticker when wall clock is set.
+	unwatch func()
+	catchUp CatchUpPolicy
+	// tracked is set when timer was created with WithTracking.
+	tracked bool
+	// missed is number of ticks not delivered since last Ticker.Missed call.
+	missed uint64
+}
+
+type timerState int
+
+const (
+	timerActive timerState = iota
+	timerExpired
+	timerStopped
+)
+
+// pendingSend is a value waiting to be received from unbuffered channel or
+// burst of ticks waiting to be received from any channel.
+type pendingSend struct {
+	cancel chan struct{}
+	done   chan struct{}
+	sent   bool
+	// n is number of values left to send. It is accessed atomically as
+	// deliver does not hold mu.
+	n uint64
+}
+
+// add adds up to n values to send keeping no more than max values pending.
+// It returns number of added values or false if delivery already finished.
+func (p *pendingSend) add(n, max uint64) (uint64, bool) {
+	for {
+		cur := atomic.LoadUint64(&p.n)
+		if cur == 0 {
+			return 0, false
+		}
+		added := n
+		if cur >= max {
+			added = 0
+		} else if cur+added > max {
+			added = max - cur
+		}
+		if atomic.CompareAndSwapUint64(&p.n, cur, cur+added) {
+			return added, true
+		}
+	}
+}
+
+func newTimerChan() chan Time {
+	if getConfig().modernTimers {
+		return make(chan Time)
+	}
+	return make(chan Time, 1)
+}
+
+func newTimerState(kind eventKind, c chan Time, f func(), opts []TimerOption) *timer {
+	r := &timer{
+		id:     nextEventID(),
+		kind:   kind,
+		c:      c,
+		f:      f,
+		modern: c != nil && cap(c) == 0,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+func (r *timer) start(d, period time.Duration) {
+	r.mu.Lock()
+	r.arm(d, period)
+	r.mu.Unlock()
+}
+
+// arm must be called with mu held. Deadline is always nominal, jitter only
+// moves actual expiration.
+func (r *timer) arm(d, period time.Duration) {
+	r.seq++
+	seq := r.seq
+	r.state = timerActive
+	r.deadline = Now().Add(d)
+	r.period = period
+	if r.jitter > 0 {
+		base := d
+		if period > 0 {
+			base = period
+		}
+		d += getConfig().jitter.duration(base, r.jitter)
+	}
+	var handler timerHandler = func(_, expirations uint64) {
+		r.fire(seq, expirations)
+	}
+	if r.precise {
+		if r.spinner == nil {
+			r.spinner = newSpinner(r.kind == tickerEvent)
+		}
+		d, handler = r.spinner.handler(d, period, handler)
+	}
+	if r.coalesced() {
+		coalesced.add(r.id, d, r.slack, handler)
+		return
+	}
+	armEvent(r.id, r.kind, d, period, handler)
+}
+
+func (r *timer) coalesced() bool {
+	return r.slack > 0 && r.kind == timerEvent
+}
+
+func (r *timer) fire(seq, expirations uint64) {
+	r.mu.Lock()
+	if seq != r.seq {
+		// Timer was stopped or reset after it expired.
+		r.mu.Unlock()
+		return
+	}
+	if r.kind == timerEvent {
+		r.state = timerExpired
+	}
+	ticks := uint64(1)
+	if r.kind == tickerEvent && expirations > 1 {
+		switch r.catchUp.mode {
+		case fireOnceWithCount:
+			r.missed += expirations - 1
+		case fireAll:
+			ticks = expirations
+		case realign:
+			if r.next == nil {
+				r.arm(r.period, r.period)
+			}
+		}
+	}
+	if r.next != nil {
+		r.arm(r.next(), r.period)
+	}
+
+	if r.f != nil {
+		r.mu.Unlock()
+		id, f := r.id, r.f
+		getConfig().executor.Execute(func() {
+			defer recoverHandler(id, timerEvent)
+			f()
+		})
+		return
+	}
+	if r.c == nil {
+		r.mu.Unlock()
+		return
+	}
+
+	max := r.catchUp.maxPending()
+	if ticks > max {
+		r.missed += ticks - max
+		ticks = max
+	}
+
+	now := Now()
+	if p := r.send; p != nil {
+		// Previous values were not received yet.
+		if added, ok := p.add(ticks, max); ok {
+			if added < ticks {
+				r.dropped(ticks - added)
+			}
+			r.mu.Unlock()
+			return
+		}
+	}
+	if ticks == 1 {
+		// Receiver which is already waiting gets value without goroutine.
+		select {
+		case r.c <- now:
+			r.mu.Unlock()
+			return
+		default:
+		}
+		if !r.modern {
+			r.dropped(1)
+			r.mu.Unlock()
+			return
+		}
+	}
+
+	p := &pendingSend{
+		cancel: make(chan struct{}),
+		done:   make(chan struct{}),
+		n:      ticks,
+	}
+	r.send = p
+	r.mu.Unlock()
+	go r.deliver(p, now)
+}
+
+// dropped must be called with mu held. It records n ticks or values which
+// were not sent because receiver was not ready.
+func (r *timer) dropped(n uint64) {
+	if r.catchUp.mode == fireAll {
+		r.missed += n
+		return
+	}
+	atomic.AddUint64(&stats.droppedSends, n)
+}
+
+func (r *timer) deliver(p *pendingSend, now Time) {
+loop:
+	for {
+		select {
+		case r.c <- now:
+			p.sent = true
+			if atomic.AddUint64(&p.n, ^uint64(0)) > 0 {
+				continue
+			}
+		case <-p.cancel:
+		}
+		break loop
+	}
+	close(p.done)
+
+	r.mu.Lock()
+	if r.send == p {
+		r.send = nil
+	}
+	r.mu.Unlock()
+}
+
+// cancelSend must be called with mu held. It reports whether pending value
+// was cancelled before it was received.
+func (r *timer) cancelSend() bool {
+	p := r.send
+	if p == nil {
+		return false
+	}
+	r.send = nil
+	close(p.cancel)
+	<-p.done
+	return !p.sent
+}
+
+func (r *timer) stop() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	active := r.state == timerActive
+	pending := r.cancelSend()
+	r.seq++
+	r.state = timerStopped
+	r.stopWatch()
+	if r.spinner != nil {
+		r.spinner.stop()
+		r.spinner = nil
+	}
+	if r.coalesced() {
+		coalesced.remove(r.id)
+	} else {
+		stopEvent(r.id)
+	}
+	return active || pending
+}
+
+func (r *timer) reset(d, period time.Duration) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	active := r.state == timerActive
+	pending := r.cancelSend()
+	r.next = nil
+	r.stopWatch()
+	r.arm(d, period)
+	return active || pending
+}
+
+// stopWatch must be called with mu held.
+func (r *timer) stopWatch() {
+	if r.unwatch != nil {
+		r.unwatch()
+		r.unwatch = nil
+	}
+}
+
+// unreceived reports whether timer has a value which was sent or is being
+// sent to its channel, but was not received yet.
+func (r *timer) unreceived() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.send != nil && atomic.LoadUint64(&r.send.n) > 0 {
+		return true
+	}
+	return r.c != nil && len(r.c) > 0
+}
+
+func (r *timer) takeMissed() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	missed := r.missed
+	r.missed = 0
+	return missed
+}
+
+func (r *timer) remaining() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.state != timerActive {
+		return 0
+	}
+	if r.coalesced() {
+		return coalesced.remaining(r.id)
+	}
+	return remainingEvent(r.id)
+}
+
+func (r *timer) getDeadline() Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.deadline
+}
+
+func (r *timer) fired() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.state == timerExpired
+}
@@ -0,0 +1,160 @@
+package realtime
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+// Tests below are ported from Go 1.23 time package tests for synchronous
+// timer channels.
+
+func withModernTimers() func() {
+	Configure(WithModernTimers(true))
+	return func() {
+		Configure(WithModernTimers(false))
+	}
+}
+
+func assertNoValue(t *testing.T, c <-chan Time) {
+	t.Helper()
+	select {
+	case v := <-c:
+		t.Fatalf("unexpected value %v", v)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func assertValue(t *testing.T, c <-chan Time) {
+	t.Helper()
+	select {
+	case <-c:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for value")
+	}
+}
+
+func TestModernTimerChan(t *testing.T) {
+	defer withModernTimers()()
+
+	timer := NewTimer(time.Hour)
+	defer timer.Stop()
+	if n := cap(timer.C); n != 0 {
+		t.Fatalf("cap(timer.C) = %d, want 0", n)
+	}
+	ticker := NewTicker(time.Hour)
+	defer ticker.Stop()
+	if n := cap(ticker.C); n != 0 {
+		t.Fatalf("cap(ticker.C) = %d, want 0", n)
+	}
+}
+
+func TestModernTimerStopNoStaleValue(t *testing.T) {
+	defer withModernTimers()()
+
+	timer := NewTimer(time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if !timer.Stop() {
+		t.Fatal("Stop of expired but not received timer = false, want true")
+	}
+	assertNoValue(t, timer.C)
+	if timer.Stop() {
+		t.Fatal("second Stop = true, want false")
+	}
+}
+
+func TestModernTimerResetNoStaleValue(t *testing.T) {
+	defer withModernTimers()()
+
+	timer := NewTimer(time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if !timer.Reset(time.Hour) {
+		t.Fatal("Reset of expired but not received timer = false, want true")
+	}
+	assertNoValue(t, timer.C)
+
+	if !timer.Reset(time.Millisecond) {
+		t.Fatal("Reset of active timer = false, want true")
+	}
+	assertValue(t, timer.C)
+	assertNoValue(t, timer.C)
+
+	if timer.Reset(time.Millisecond) {
+		t.Fatal("Reset of received timer = true, want false")
+	}
+	assertValue(t, timer.C)
+	timer.Stop()
+}
+
+func TestModernTickerStopNoStaleValue(t *testing.T) {
+	defer withModernTimers()()
+
+	ticker := NewTicker(time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	ticker.Stop()
+	assertNoValue(t, ticker.C)
+}
+
+func TestModernTimerReceiveAfterStopBlocks(t *testing.T) {
+	defer withModernTimers()()
+
+	// Mirrors TestAfterStop of the time package.
+	t0 := NewTimer(100 * time.Millisecond)
+	t1 := NewTimer(200 * time.Millisecond)
+	if !t0.Stop() {
+		t.Fatal("failed to stop event 0")
+	}
+	if !t1.Stop() {
+		t.Fatal("failed to stop event 1")
+	}
+	c := After(300 * time.Millisecond)
+	select {
+	case <-t0.C:
+		t.Fatal("event 0 was not stopped")
+	case <-t1.C:
+		t.Fatal("event 1 was not stopped")
+	case <-c:
+	}
+}
+
+func TestModernTimerGC(t *testing.T) {
+	defer withModernTimers()()
+
+	// Expired timers which are not referenced drop values nobody receives.
+	startEngine()
+	before := runtime.NumGoroutine()
+	for i := 0; i < 100; i++ {
+		NewTimer(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	for i := 0; i < 10 && runtime.NumGoroutine() > before; i++ {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Fatalf("expected unreferenced timers to stop sending, %d goroutines before, %d after", before, after)
+	}
+}
+
+func TestTimerGC(t *testing.T) {
+	startEngine()
+	for _, modern := range []bool{false, true} {
+		Configure(WithModernTimers(modern))
+		baseline := Stats().FDsInUse
+		// Armed timers and running tickers are collected too.
+		for i := 0; i < 10; i++ {
+			NewTimer(time.Hour)
+			NewTicker(time.Hour)
+			NewJitteredTicker(time.Hour, 0.1)
+			NewAlignedTicker(time.Hour, 0)
+		}
+		if Stats().FDsInUse <= baseline {
+			t.Fatal("expected timers to use fds")
+		}
+		waitFor(t, func() bool {
+			runtime.GC()
+			return Stats().FDsInUse == baseline
+		})
+	}
+	Configure(WithModernTimers(false))
+}
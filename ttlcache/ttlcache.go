@@ -0,0 +1,263 @@
+// Package ttlcache provides cache with entries which expire after their TTL
+// measured by realtime clock, so entries do not outlive their TTL because of
+// suspend.
+package ttlcache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/anjmao/realtime"
+	"github.com/anjmao/realtime/internal/deadlineheap"
+)
+
+// EvictionReason tells why entry was removed from cache.
+type EvictionReason int
+
+const (
+	// Expired entry outlived its TTL.
+	Expired EvictionReason = iota
+	// Capacity entry was least recently used when cache was full.
+	Capacity
+)
+
+func (r EvictionReason) String() string {
+	if r == Capacity {
+		return "capacity"
+	}
+	return "expired"
+}
+
+// Option configures Cache.
+type Option func(*Cache)
+
+// WithMaxSize bounds number of entries, least recently used entries are
+// evicted when cache is full. Zero means no bound.
+func WithMaxSize(n int) Option {
+	return func(c *Cache) {
+		c.maxSize = n
+	}
+}
+
+// WithEvictionCallback sets function called when entry expires or is
+// evicted. It is not called for deleted or replaced entries.
+func WithEvictionCallback(f func(key, value interface{}, reason EvictionReason)) Option {
+	return func(c *Cache) {
+		c.onEvict = f
+	}
+}
+
+// WithClock sets clock used for expiry.
+func WithClock(clock realtime.Clock) Option {
+	return func(c *Cache) {
+		c.clock = clock
+	}
+}
+
+// Cache is safe for concurrent use. Entries expire by a single timer armed
+// to the earliest deadline.
+type Cache struct {
+	mu      sync.Mutex
+	items   map[interface{}]*entry
+	lru     *list.List // of *entry, most recently used first
+	expiry  *deadlineheap.Heap
+	maxSize int
+	onEvict func(key, value interface{}, reason EvictionReason)
+	clock   realtime.Clock
+
+	timer realtime.ClockTimer
+	armed bool
+	// deadline timer is armed to.
+	deadline realtime.Time
+	done     chan struct{}
+	closed   sync.Once
+}
+
+type entry struct {
+	deadlineheap.Item
+	key      interface{}
+	value    interface{}
+	deadline realtime.Time
+	expires  bool
+	elem     *list.Element
+}
+
+type eviction struct {
+	e      *entry
+	reason EvictionReason
+}
+
+// New returns empty cache. Close must be called to release its timer.
+func New(opts ...Option) *Cache {
+	c := &Cache{
+		items:  map[interface{}]*entry{},
+		lru:    list.New(),
+		expiry: deadlineheap.New(expiresBefore),
+		clock:  realtime.SystemClock(),
+		done:   make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.timer = c.clock.NewTimer(time.Hour)
+	c.timer.Stop()
+	go c.run()
+	return c
+}
+
+// Set adds or replaces entry which expires after ttl. Entry with
+// non-positive ttl does not expire.
+func (c *Cache) Set(key, value interface{}, ttl time.Duration) {
+	c.mu.Lock()
+	now := c.clock.Now()
+	e, ok := c.items[key]
+	if ok {
+		c.lru.MoveToFront(e.elem)
+		c.expiry.Remove(e)
+	} else {
+		e = &entry{key: key}
+		e.elem = c.lru.PushFront(e)
+		c.items[key] = e
+	}
+	e.value = value
+	e.expires = ttl > 0
+	if e.expires {
+		e.deadline = now.Add(ttl)
+		c.expiry.Push(e)
+	}
+
+	var evicted []eviction
+	for c.maxSize > 0 && len(c.items) > c.maxSize {
+		oldest := c.lru.Back().Value.(*entry)
+		c.remove(oldest)
+		evicted = append(evicted, eviction{oldest, Capacity})
+	}
+	c.rearm(now)
+	c.mu.Unlock()
+
+	c.notify(evicted)
+}
+
+// Get returns value of entry which has not expired.
+func (c *Cache) Get(key interface{}) (interface{}, bool) {
+	c.mu.Lock()
+	e, ok := c.items[key]
+	if !ok {
+		c.mu.Unlock()
+		return nil, false
+	}
+	if now := c.clock.Now(); e.expires && !e.deadline.After(now) {
+		// Timer has not expired entry yet.
+		c.remove(e)
+		c.rearm(now)
+		c.mu.Unlock()
+		c.notify([]eviction{{e, Expired}})
+		return nil, false
+	}
+	c.lru.MoveToFront(e.elem)
+	c.mu.Unlock()
+	return e.value, true
+}
+
+// Delete removes entry and reports whether it was present.
+func (c *Cache) Delete(key interface{}) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[key]
+	if ok {
+		c.remove(e)
+		c.rearm(c.clock.Now())
+	}
+	return ok
+}
+
+// Len returns number of entries including expired ones which were not
+// removed yet.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items)
+}
+
+// Close stops expiry timer. Entries do not expire after Close, but Get still
+// does not return expired entries.
+func (c *Cache) Close() {
+	c.closed.Do(func() {
+		close(c.done)
+		c.mu.Lock()
+		c.timer.Stop()
+		c.mu.Unlock()
+	})
+}
+
+func (c *Cache) run() {
+	for {
+		select {
+		case <-c.timer.Chan():
+			c.expire()
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *Cache) expire() {
+	c.mu.Lock()
+	now := c.clock.Now()
+	c.armed = false
+	var evicted []eviction
+	for c.expiry.Len() > 0 && !c.expiry.Peek().(*entry).deadline.After(now) {
+		e := c.expiry.Peek().(*entry)
+		c.remove(e)
+		evicted = append(evicted, eviction{e, Expired})
+	}
+	c.rearm(now)
+	c.mu.Unlock()
+
+	c.notify(evicted)
+}
+
+// remove must be called with mu held.
+func (c *Cache) remove(e *entry) {
+	delete(c.items, e.key)
+	c.lru.Remove(e.elem)
+	c.expiry.Remove(e)
+}
+
+// rearm must be called with mu held. Timer is reset only when the earliest
+// deadline changes, not on every Set.
+func (c *Cache) rearm(now realtime.Time) {
+	select {
+	case <-c.done:
+		return
+	default:
+	}
+	if c.expiry.Len() == 0 {
+		if c.armed {
+			c.timer.Stop()
+			c.armed = false
+		}
+		return
+	}
+	deadline := c.expiry.Peek().(*entry).deadline
+	if c.armed && deadline == c.deadline {
+		return
+	}
+	c.armed = true
+	c.deadline = deadline
+	c.timer.Reset(deadline.Sub(now))
+}
+
+func (c *Cache) notify(evicted []eviction) {
+	if c.onEvict == nil {
+		return
+	}
+	for _, ev := range evicted {
+		c.onEvict(ev.e.key, ev.e.value, ev.reason)
+	}
+}
+
+func expiresBefore(a, b deadlineheap.Elem) bool {
+	return a.(*entry).deadline.Before(b.(*entry).deadline)
+}
@@ -0,0 +1,170 @@
+package ttlcache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/anjmao/realtime/internal/clocktest"
+)
+
+type evictedEntry struct {
+	key    interface{}
+	reason EvictionReason
+}
+
+func newTestCache(opts ...Option) (*Cache, *clocktest.Clock, chan evictedEntry) {
+	clock := clocktest.New()
+	evicted := make(chan evictedEntry, 100)
+	opts = append(opts, WithClock(clock), WithEvictionCallback(func(key, value interface{}, reason EvictionReason) {
+		evicted <- evictedEntry{key, reason}
+	}))
+	return New(opts...), clock, evicted
+}
+
+func expectEvicted(t *testing.T, evicted chan evictedEntry, key interface{}, reason EvictionReason) {
+	t.Helper()
+	select {
+	case e := <-evicted:
+		if e.key != key || e.reason != reason {
+			t.Fatalf("expected %v to be evicted as %s, got %v as %s", key, reason, e.key, e.reason)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected %v to be evicted", key)
+	}
+}
+
+func TestSetGetDelete(t *testing.T) {
+	c, _, _ := newTestCache()
+	defer c.Close()
+
+	c.Set("a", 1, time.Minute)
+	c.Set("b", 2, 0)
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatalf("expected a=1, got %v, %v", v, ok)
+	}
+	c.Set("a", 3, time.Minute)
+	if v, _ := c.Get("a"); v != 3 {
+		t.Fatalf("expected a=3, got %v", v)
+	}
+	if !c.Delete("a") || c.Delete("a") {
+		t.Fatal("expected a to be deleted once")
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("expected a to be missing")
+	}
+	if c.Len() != 1 {
+		t.Fatalf("expected 1 entry, got %d", c.Len())
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	c, clock, evicted := newTestCache()
+	defer c.Close()
+
+	c.Set("late", 1, 3*time.Minute)
+	c.Set("early", 2, time.Minute)
+	c.Set("forever", 3, 0)
+	c.Set("middle", 4, 2*time.Minute)
+	if n := clock.Timers(); n != 1 {
+		t.Fatalf("expected single timer, got %d", n)
+	}
+
+	clock.Advance(time.Minute)
+	expectEvicted(t, evicted, "early", Expired)
+	clock.Advance(time.Minute)
+	expectEvicted(t, evicted, "middle", Expired)
+
+	// Suspend expires remaining entries.
+	clock.Advance(8 * time.Hour)
+	expectEvicted(t, evicted, "late", Expired)
+	if _, ok := c.Get("forever"); !ok {
+		t.Fatal("expected entry without ttl to stay")
+	}
+	waitTimers(t, clock, 0)
+}
+
+func TestExpiryRearm(t *testing.T) {
+	c, clock, evicted := newTestCache()
+	defer c.Close()
+
+	c.Set("a", 1, time.Hour)
+	// Shorter TTL re-arms timer earlier.
+	c.Set("b", 2, time.Minute)
+	clock.Advance(time.Minute)
+	expectEvicted(t, evicted, "b", Expired)
+
+	// Replaced entry gets new deadline.
+	c.Set("a", 1, 2*time.Hour)
+	clock.Advance(time.Hour)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("expected replaced entry to stay")
+	}
+	c.Delete("a")
+	waitTimers(t, clock, 0)
+}
+
+func TestGetExpiredBeforeTimer(t *testing.T) {
+	c, clock, evicted := newTestCache()
+	c.Close()
+
+	c.Set("a", 1, time.Minute)
+	clock.Advance(time.Minute)
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("expected expired entry to be missing")
+	}
+	expectEvicted(t, evicted, "a", Expired)
+}
+
+func TestMaxSize(t *testing.T) {
+	c, _, evicted := newTestCache(WithMaxSize(3))
+	defer c.Close()
+
+	for i := 0; i < 3; i++ {
+		c.Set(i, i, time.Minute)
+	}
+	c.Get(0)
+	c.Set(3, 3, time.Minute)
+	expectEvicted(t, evicted, 1, Capacity)
+	c.Set(4, 4, 0)
+	expectEvicted(t, evicted, 2, Capacity)
+	if c.Len() != 3 {
+		t.Fatalf("expected 3 entries, got %d", c.Len())
+	}
+	for _, key := range []int{0, 3, 4} {
+		if _, ok := c.Get(key); !ok {
+			t.Fatalf("expected %d to stay", key)
+		}
+	}
+}
+
+func TestSystemClock(t *testing.T) {
+	evicted := make(chan interface{}, 1)
+	c := New(WithEvictionCallback(func(key, value interface{}, reason EvictionReason) {
+		evicted <- key
+	}))
+	defer c.Close()
+	c.Set("a", 1, 10*time.Millisecond)
+	if key := <-evicted; key != "a" {
+		t.Fatalf("expected a to expire, got %v", key)
+	}
+}
+
+func waitTimers(t *testing.T, clock *clocktest.Clock, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for clock.Timers() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d timers, got %d", n, clock.Timers())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func BenchmarkSet(b *testing.B) {
+	c := New(WithMaxSize(1000))
+	defer c.Close()
+	for i := 0; i < b.N; i++ {
+		c.Set(fmt.Sprint(i%2000), i, time.Minute)
+	}
+}
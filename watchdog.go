@@ -0,0 +1,281 @@
+package realtime
+
+import (
+	"sync"
+	"time"
+
+	"github.com/anjmao/realtime/internal/deadlineheap"
+)
+
+// ExpireReason tells why Watchdog or HeartbeatMonitor peer expired.
+type ExpireReason int
+
+const (
+	// PeerSilent means there was no heartbeat within timeout.
+	PeerSilent ExpireReason = iota
+	// HostSuspended means timeout passed while this host was suspended, so
+	// peer may have been unable to reach it. It is reported only with
+	// WithSuspendDetection.
+	HostSuspended
+)
+
+func (r ExpireReason) String() string {
+	if r == HostSuspended {
+		return "host suspended"
+	}
+	return "peer silent"
+}
+
+// minSuspend is suspend duration ignored by suspend detection, realtime and
+// awake clocks are not read at the same instant.
+const minSuspend = time.Millisecond
+
+var awakeStart = time.Now()
+
+// awakeNow returns time this process was awake, Go monotonic clock does not
+// advance during suspend.
+var awakeNow = func() time.Duration {
+	return time.Since(awakeStart)
+}
+
+// WatchdogOption configures Watchdog or HeartbeatMonitor.
+type WatchdogOption func(*watchdogConfig)
+
+type watchdogConfig struct {
+	detectSuspend bool
+}
+
+// WithSuspendDetection reports HostSuspended instead of PeerSilent when host
+// was suspended and timeout would not have passed if it was awake.
+func WithSuspendDetection(enabled bool) WatchdogOption {
+	return func(c *watchdogConfig) {
+		c.detectSuspend = enabled
+	}
+}
+
+// heartbeat records time of a heartbeat on realtime and awake clocks.
+type heartbeat struct {
+	at    Time
+	awake time.Duration
+}
+
+func newHeartbeat() heartbeat {
+	return heartbeat{at: Now(), awake: awakeNow()}
+}
+
+func (h heartbeat) reason(timeout time.Duration, cfg watchdogConfig) ExpireReason {
+	if !cfg.detectSuspend {
+		return PeerSilent
+	}
+	awake := awakeNow() - h.awake
+	if suspended := Since(h.at) - awake; suspended > minSuspend && awake < timeout {
+		return HostSuspended
+	}
+	return PeerSilent
+}
+
+// Watchdog calls function when it is not kicked within timeout. Timeout is
+// measured by realtime clock, so watchdog expires on wake up if timeout
+// passed during suspend.
+type Watchdog struct {
+	mu       sync.Mutex
+	timeout  time.Duration
+	onExpire func(ExpireReason)
+	cfg      watchdogConfig
+	timer    *Timer
+	armed    bool
+	deadline Time
+	last     heartbeat
+}
+
+// NewWatchdog returns armed watchdog which calls onExpire from timer callback
+// when Kick is not called within timeout.
+func NewWatchdog(timeout time.Duration, onExpire func(ExpireReason), opts ...WatchdogOption) *Watchdog {
+	w := &Watchdog{
+		timeout:  timeout,
+		onExpire: onExpire,
+	}
+	for _, opt := range opts {
+		opt(&w.cfg)
+	}
+	w.Kick()
+	return w
+}
+
+// Kick postpones expiry by timeout. It re-arms expired or stopped watchdog.
+func (w *Watchdog) Kick() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.last = newHeartbeat()
+	w.armed = true
+	w.deadline = w.last.at.Add(w.timeout)
+	w.timer = rearmFunc(w.timer, w.timeout, w.fire)
+}
+
+// Stop disarms watchdog and reports whether it was armed.
+func (w *Watchdog) Stop() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	armed := w.armed
+	w.armed = false
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	return armed
+}
+
+func (w *Watchdog) fire() {
+	w.mu.Lock()
+	if !w.armed || Now().Before(w.deadline) {
+		// Stopped or kicked after timer expired.
+		w.mu.Unlock()
+		return
+	}
+	w.armed = false
+	reason := w.last.reason(w.timeout, w.cfg)
+	w.mu.Unlock()
+
+	w.onExpire(reason)
+}
+
+// HeartbeatMonitor tracks heartbeats of many peers and calls function for
+// every peer which is silent for timeout. Expired peers are forgotten until
+// their next heartbeat. All peers share a single timer.
+type HeartbeatMonitor struct {
+	mu       sync.Mutex
+	timeout  time.Duration
+	onExpire func(id string, reason ExpireReason)
+	cfg      watchdogConfig
+	peers    map[string]*peer
+	expiry   *deadlineheap.Heap
+	timer    *Timer
+	// armed is set while timer waits for deadline of the earliest peer.
+	armed    bool
+	deadline Time
+	stopped  bool
+}
+
+type peer struct {
+	deadlineheap.Item
+	id       string
+	last     heartbeat
+	deadline Time
+}
+
+type peerExpiry struct {
+	id     string
+	reason ExpireReason
+}
+
+// NewHeartbeatMonitor returns monitor without peers. Peers are added by
+// their first heartbeat.
+func NewHeartbeatMonitor(timeout time.Duration, onExpire func(id string, reason ExpireReason), opts ...WatchdogOption) *HeartbeatMonitor {
+	m := &HeartbeatMonitor{
+		timeout:  timeout,
+		onExpire: onExpire,
+		peers:    map[string]*peer{},
+		expiry:   deadlineheap.New(peerBefore),
+	}
+	for _, opt := range opts {
+		opt(&m.cfg)
+	}
+	return m
+}
+
+// Beat records heartbeat of peer.
+func (m *HeartbeatMonitor) Beat(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stopped {
+		return
+	}
+	p, ok := m.peers[id]
+	if !ok {
+		p = &peer{id: id}
+		m.peers[id] = p
+	}
+	p.last = newHeartbeat()
+	p.deadline = p.last.at.Add(m.timeout)
+	m.expiry.Fix(p)
+	m.rearm()
+}
+
+// Remove stops tracking peer and reports whether it was tracked.
+func (m *HeartbeatMonitor) Remove(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.peers[id]
+	if ok {
+		delete(m.peers, id)
+		m.expiry.Remove(p)
+		m.rearm()
+	}
+	return ok
+}
+
+// LastBeat returns time of the last heartbeat of tracked peer.
+func (m *HeartbeatMonitor) LastBeat(id string) (Time, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.peers[id]
+	if !ok {
+		return Time{}, false
+	}
+	return p.last.at, true
+}
+
+// Stop stops monitoring, peers do not expire after Stop.
+func (m *HeartbeatMonitor) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stopped = true
+	m.armed = false
+	if m.timer != nil {
+		m.timer.Stop()
+	}
+}
+
+func (m *HeartbeatMonitor) fire() {
+	m.mu.Lock()
+	if m.stopped {
+		m.mu.Unlock()
+		return
+	}
+	now := Now()
+	m.armed = false
+	var expired []peerExpiry
+	for m.expiry.Len() > 0 && !m.expiry.Peek().(*peer).deadline.After(now) {
+		p := m.expiry.Pop().(*peer)
+		delete(m.peers, p.id)
+		expired = append(expired, peerExpiry{p.id, p.last.reason(m.timeout, m.cfg)})
+	}
+	m.rearm()
+	m.mu.Unlock()
+
+	for _, e := range expired {
+		m.onExpire(e.id, e.reason)
+	}
+}
+
+// rearm must be called with mu held. Beat of any peer other than the
+// earliest one does not touch the timer.
+func (m *HeartbeatMonitor) rearm() {
+	if m.expiry.Len() == 0 {
+		if m.armed {
+			m.timer.Stop()
+			m.armed = false
+		}
+		return
+	}
+	deadline := m.expiry.Peek().(*peer).deadline
+	if m.armed && deadline == m.deadline {
+		return
+	}
+	m.armed = true
+	m.deadline = deadline
+	m.timer = rearmFunc(m.timer, deadline.Sub(Now()), m.fire)
+}
+
+func peerBefore(a, b deadlineheap.Elem) bool {
+	return a.(*peer).deadline.Before(b.(*peer).deadline)
+}
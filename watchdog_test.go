@@ -0,0 +1,122 @@
+package realtime
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+type expiryRecorder struct {
+	mu      sync.Mutex
+	expired []string
+}
+
+func (r *expiryRecorder) watchdog(reason ExpireReason) {
+	r.peer("", reason)
+}
+
+func (r *expiryRecorder) peer(id string, reason ExpireReason) {
+	r.mu.Lock()
+	r.expired = append(r.expired, id+":"+reason.String())
+	r.mu.Unlock()
+}
+
+func (r *expiryRecorder) expect(t *testing.T, expired ...string) {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sort.Strings(r.expired)
+	if len(r.expired) != len(expired) {
+		t.Fatalf("expected %v expired, got %v", expired, r.expired)
+	}
+	for i := range expired {
+		if r.expired[i] != expired[i] {
+			t.Fatalf("expected %v expired, got %v", expired, r.expired)
+		}
+	}
+	r.expired = nil
+}
+
+func TestWatchdog(t *testing.T) {
+	p, restore := useInlineFakePoller()
+	defer restore()
+
+	var r expiryRecorder
+	w := NewWatchdog(time.Second, r.watchdog)
+	for i := 0; i < 5; i++ {
+		p.advance(500 * time.Millisecond)
+		w.Kick()
+	}
+	r.expect(t)
+	p.advance(time.Second)
+	r.expect(t, ":peer silent")
+
+	// Kick re-arms expired watchdog.
+	w.Kick()
+	p.advance(time.Second)
+	r.expect(t, ":peer silent")
+
+	w.Kick()
+	if !w.Stop() {
+		t.Fatal("expected Stop of armed watchdog to return true")
+	}
+	if w.Stop() {
+		t.Fatal("expected Stop of stopped watchdog to return false")
+	}
+	p.advance(time.Second)
+	r.expect(t)
+}
+
+func TestWatchdogSuspend(t *testing.T) {
+	p, restore := useInlineFakePoller()
+	defer restore()
+
+	var r expiryRecorder
+	w := NewWatchdog(time.Minute, r.watchdog)
+	detecting := NewWatchdog(time.Minute, r.watchdog, WithSuspendDetection(true))
+	p.advance(30 * time.Second)
+	p.suspend(time.Hour)
+	r.expect(t, ":host suspended", ":peer silent")
+
+	// Suspend shorter than silence is blamed on peer.
+	detecting.Kick()
+	p.advance(time.Minute)
+	p.suspend(time.Second)
+	r.expect(t, ":peer silent")
+	w.Stop()
+}
+
+func TestHeartbeatMonitor(t *testing.T) {
+	p, restore := useInlineFakePoller()
+	defer restore()
+
+	var r expiryRecorder
+	m := NewHeartbeatMonitor(time.Second, r.peer, WithSuspendDetection(true))
+	defer m.Stop()
+
+	m.Beat("a")
+	m.Beat("b")
+	m.Beat("c")
+	p.advance(500 * time.Millisecond)
+	m.Beat("a")
+	if !m.Remove("c") {
+		t.Fatal("expected Remove of tracked peer to return true")
+	}
+	p.advance(500 * time.Millisecond)
+	r.expect(t, "b:peer silent")
+
+	if last, ok := m.LastBeat("a"); !ok || Since(last) != 500*time.Millisecond {
+		t.Fatalf("expected last beat of a 500ms ago, got %s, %v", Since(last), ok)
+	}
+	if _, ok := m.LastBeat("b"); ok {
+		t.Fatal("expected expired peer to be forgotten")
+	}
+	p.advance(500 * time.Millisecond)
+	r.expect(t, "a:peer silent")
+
+	m.Beat("a")
+	m.Beat("b")
+	p.suspend(time.Hour)
+	r.expect(t, "a:host suspended", "b:host suspended")
+}